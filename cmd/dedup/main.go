@@ -0,0 +1,90 @@
+// This package removes the duplicated rows that were inserted before the inserts were made idempotent.
+// It is meant to be run once: it finds the log index ranges with duplicated rows and rewrites each of them
+// keeping a single row per log index.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/naveensrinivasan/rekor-phren/pkg"
+)
+
+func main() {
+	app := &cli.App{
+		Name:  "dedup",
+		Usage: "dedup --dataset <dataset> --table <table> rewrites the log index ranges that have duplicated rows",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "project",
+				Usage:   "GCP project of the dataset, detected from the credentials when it is not set",
+				EnvVars: []string{"PHREN_PROJECT", "GOOGLE_CLOUD_PROJECT"},
+			},
+			&cli.StringFlag{
+				Name:    "dataset",
+				Usage:   "Name of the dataset",
+				Value:   "phren",
+				EnvVars: []string{"DATASET"},
+			},
+			&cli.StringFlag{
+				Name:    "table",
+				Usage:   "Name of the table",
+				Value:   "phren",
+				EnvVars: []string{"TABLE"},
+			},
+			&cli.Int64Flag{
+				Name:    "range-size",
+				Usage:   "number of log indexes rewritten by a single query",
+				Value:   100000,
+				EnvVars: []string{"RANGE_SIZE"},
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "only print the ranges with duplicated rows",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			dataset := c.String("dataset")
+			tableName := c.String("table")
+
+			bq, err := pkg.NewBigQuery(c.String("project"))
+			if err != nil {
+				return err
+			}
+			//nolint
+			defer bq.Close()
+			ranges, err := bq.GetDuplicateRanges(dataset, tableName, c.Int64("range-size"))
+			if err != nil {
+				return err
+			}
+			if len(ranges) == 0 {
+				log.Println("No duplicated rows found")
+				return nil
+			}
+			var total int64
+			for _, r := range ranges {
+				total += r.Duplicates
+			}
+			log.Printf("found %d duplicated rows in %d ranges\n", total, len(ranges))
+			for _, r := range ranges {
+				if c.Bool("dry-run") {
+					fmt.Printf("%d-%d %d\n", r.Start, r.End, r.Duplicates)
+					continue
+				}
+				if err := bq.DeduplicateRange(dataset, tableName, r.Start, r.End); err != nil {
+					return fmt.Errorf("failed to deduplicate %d-%d %w", r.Start, r.End, err)
+				}
+				log.Printf("removed %d duplicated rows from %d-%d\n", r.Duplicates, r.Start, r.End)
+			}
+			return nil
+		},
+	}
+
+	err := app.Run(os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
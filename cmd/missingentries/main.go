@@ -27,6 +27,11 @@ func main() {
 	app := &cli.App{
 		Name: "missing-entries",
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "project",
+				Usage:   "GCP project of the dataset, detected from the credentials when it is not set",
+				EnvVars: []string{"PHREN_PROJECT", "GOOGLE_CLOUD_PROJECT"},
+			},
 			&cli.StringFlag{
 				Name:    "dataset",
 				Usage:   "Name of the dataset",
@@ -39,12 +44,61 @@ func main() {
 				Value:   "phren",
 				EnvVars: []string{"TABLE"},
 			},
+			&cli.StringFlag{
+				Name:    "sqlite-db",
+				Usage:   "look for missing entries in a local SQLite database and print them instead of creating jobs",
+				EnvVars: []string{"SQLITE_DB"},
+			},
+			&cli.StringFlag{
+				Name:    "postgres-url",
+				Usage:   "look for missing entries in a PostgreSQL database and print them instead of creating jobs",
+				EnvVars: []string{"POSTGRES_URL"},
+			},
+			&cli.StringFlag{
+				Name:    "postgres-table",
+				Usage:   "name of the PostgreSQL entries table",
+				Value:   "entries",
+				EnvVars: []string{"PHREN_POSTGRES_TABLE"},
+			},
 		},
 		Action: func(c *cli.Context) error {
 			dataset := c.String("dataset")
 			tableName := c.String("table")
 
-			missing, err := pkg.GetMissingEntries(dataset, tableName)
+			var store pkg.Store
+			var err error
+			switch {
+			case c.String("sqlite-db") != "":
+				store, err = pkg.NewSQLiteStore(c.String("sqlite-db"))
+			case c.String("postgres-url") != "":
+				store, err = pkg.NewPostgresStore(pkg.PostgresOptions{
+					URL:      c.String("postgres-url"),
+					Table:    c.String("postgres-table"),
+					ReadOnly: true,
+				})
+			}
+			if err != nil {
+				return err
+			}
+			if store != nil {
+				defer store.Close()
+				missing, err := store.GetMissingEntries(dataset, tableName)
+				if err != nil {
+					return err
+				}
+				for _, id := range missing {
+					fmt.Println(id)
+				}
+				return nil
+			}
+
+			bq, err := pkg.NewBigQuery(c.String("project"))
+			if err != nil {
+				return err
+			}
+			//nolint
+			defer bq.Close()
+			missing, err := bq.GetMissingEntries(dataset, tableName)
 			if err != nil {
 				return err
 			}
@@ -54,7 +108,7 @@ func main() {
 			}
 			fmt.Println(missing)
 			for i, id := range missing {
-				createJob(int(id))
+				createJob(int(id), c.String("project"))
 				if i%100 == 0 {
 					log.Println("exiting after 100 jobs")
 					break
@@ -79,7 +133,7 @@ func buildConfig(kubeconfig string) (*rest.Config, error) {
 	}
 	return clientcmd.BuildConfigFromFlags("", filepath.Join(homedir.HomeDir(), ".kube", "config"))
 }
-func createJob(id int) {
+func createJob(id int, project string) {
 	config, err := buildConfig("")
 	if err != nil {
 		panic(err.Error())
@@ -90,6 +144,12 @@ func createJob(id int) {
 		panic(err.Error())
 	}
 	jobsClient := clientset.BatchV1().Jobs("default")
+	command := []string{"rekor-phren"}
+	if project != "" {
+		command = append(command, "--bigquery-project", project)
+	}
+	command = append(command, "--bigquery-dataset", "phren", "--bigquery-table-name", "rekor",
+		"--rekor-url", "http://10.117.1.69", "--start-index", fmt.Sprintf("%d", id), "--end-index", fmt.Sprintf("%d", id+1), "update")
 	const image = "gcr.io/openssf/rekor-phren-c5fc4a6e85fec69cce84b35fd28b14cc@sha256:4a52ce50e4e240b84d69e04f87d3df684f03a21640cb9229bd4fe8f63b1afc43"
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
@@ -101,11 +161,9 @@ func createJob(id int) {
 				Spec: corev1.PodSpec{
 					Containers: []corev1.Container{
 						{
-							Name:  "test",
-							Image: image,
-							Command: []string{"rekor-phren", "--bigquery-dataset", "phren", "--bigquery-table-name",
-								"rekor", "--rekor-url", "http://10.117.1.69", "--start-index", fmt.Sprintf("%d", id),
-								"--end-index", fmt.Sprintf("%d", id+1), "update"},
+							Name:    "test",
+							Image:   image,
+							Command: command,
 						},
 					},
 					RestartPolicy:      corev1.RestartPolicyNever,
@@ -39,8 +39,12 @@ func main() {
 	dataset := "phren"
 	// the namespace to use for k8s for the job
 	namespace := "default"
+	// the GCP project of the dataset, detected from the credentials when it is empty
+	project := ""
+	// the GCS bucket the jobs archive the entries in, the default bucket of rekor-phren when it is empty
+	bucket := ""
 
-	app := handleCommandline(hostname, dataset, table, namespace)
+	app := handleCommandline(hostname, project, dataset, table, bucket, namespace)
 	if err := app.Run(os.Args); err != nil {
 		log.Fatal(err)
 	}
@@ -48,7 +52,8 @@ func main() {
 
 // handleCommandline handles the commandline arguments
 //nolint:funlen
-func handleCommandline(hostname string, dataset string, table string, namespace string) *cli.App {
+func handleCommandline(hostname string, project string, dataset string, table string, bucket string,
+	namespace string) *cli.App {
 	app := cli.NewApp()
 	app.Name = "phren-scan"
 	app.Usage = "phren scan looks for new entries in rekor and invokes the phren job to update the BigQuery table and the bucket with the rekor entries"
@@ -60,6 +65,13 @@ func handleCommandline(hostname string, dataset string, table string, namespace
 			Usage:       "URL to the rekor server",
 			Destination: &hostname,
 		},
+		&cli.StringFlag{
+			Name:        "project",
+			Value:       project,
+			Usage:       "GCP project of the dataset, detected from the credentials when it is not set",
+			Destination: &project,
+			EnvVars:     []string{"PHREN_PROJECT", "GOOGLE_CLOUD_PROJECT"},
+		},
 		&cli.StringFlag{
 			Name:        "dataset",
 			Value:       dataset,
@@ -72,12 +84,23 @@ func handleCommandline(hostname string, dataset string, table string, namespace
 			Usage:       "table to use table for bigquery",
 			Destination: &table,
 		},
+		&cli.StringFlag{
+			Name:        "bucket",
+			Value:       bucket,
+			Usage:       "GCS bucket the jobs archive the entries in and stage the load jobs in",
+			Destination: &bucket,
+			EnvVars:     []string{"PHREN_BUCKET"},
+		},
 		&cli.StringFlag{
 			Name:        "namespace",
 			Value:       namespace,
 			Usage:       "namespace to use for k8s",
 			Destination: &namespace,
 		},
+		&cli.BoolFlag{
+			Name:  "load-jobs",
+			Usage: "the jobs stage the entries in GCS and load them with BigQuery load jobs instead of streaming inserts, for backfills",
+		},
 	}
 
 	app.Action = func(c *cli.Context) error {
@@ -94,9 +117,14 @@ func handleCommandline(hostname string, dataset string, table string, namespace
 			}
 		}
 
-		p := pkg.New()
+		p, err := pkg.NewBigQuery(project)
+		if err != nil {
+			return fmt.Errorf("error creating bigquery client: %w", err)
+		}
+		//nolint
+		defer p.Close()
 		t := pkg.NewTLog(hostname)
-		k, err := k8s.New(p, t, hostname, dataset, table, namespace)
+		k, err := k8s.New(p, t, hostname, project, dataset, table, bucket, namespace)
 
 		if err != nil {
 			return fmt.Errorf("error creating k8s client: %w", err)
@@ -115,7 +143,11 @@ func handleCommandline(hostname string, dataset string, table string, namespace
 
 		for _, r := range result {
 			// creates a k8s job for each chunk
-			if err := k.CreateJob(r); err != nil {
+			create := k.CreateJob
+			if c.Bool("load-jobs") {
+				create = k.CreateLoadJob
+			}
+			if err := create(r); err != nil {
 				panic(err)
 			}
 		}
@@ -1,22 +1,183 @@
 package main
 
 import (
-	"github.com/naveensrinivasan/rekor-phren/pkg"
+	"fmt"
+	"log"
 	"os"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/urfave/cli/v2"
+
+	"github.com/naveensrinivasan/rekor-phren/pkg"
 )
 
 func main() {
-	var datasetName, tableName string
-	datasetName = "phren"
-	tableName = "rekor"
-	if len(os.Args) > 3 {
-		datasetName = os.Args[1]
-		tableName = os.Args[2]
-	}
-	// this func updates the BigQuery table schema
-	k := pkg.Entry{}
-	err := pkg.CreateOrUpdateSchema(k, datasetName, tableName)
+	app := &cli.App{
+		Name:  "schema",
+		Usage: "schema --dataset <dataset> --table <table> plan|apply|export|diff manages the schema of the BigQuery table",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "project",
+				Usage:   "GCP project of the dataset, detected from the credentials when it is not set",
+				EnvVars: []string{"PHREN_PROJECT", "GOOGLE_CLOUD_PROJECT"},
+			},
+			&cli.StringFlag{
+				Name:    "dataset",
+				Usage:   "Name of the dataset",
+				Value:   "phren",
+				EnvVars: []string{"DATASET"},
+			},
+			&cli.StringFlag{
+				Name:    "table",
+				Usage:   "Name of the table",
+				Value:   "rekor",
+				EnvVars: []string{"TABLE"},
+			},
+		},
+		Commands: []*cli.Command{
+			{
+				Name:   "plan",
+				Usage:  "prints the migrations that have not been applied to the table and the columns they change",
+				Action: plan,
+			},
+			{
+				Name: "apply",
+				Usage: "applies the migrations that have not been applied to the table and records them in schema_migrations, " +
+					"migrations that rewrite or partition the table replace its rows and need the writers to be stopped",
+				Action: apply,
+			},
+			{
+				Name:  "export",
+				Usage: "prints the schema of the entries, it does not need credentials",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "bigquery for a BigQuery JSON schema file, jsonschema for the JSON Schema of the entry.json objects, avro for the Avro schema of the rows",
+						Value: "bigquery",
+					},
+					&cli.StringFlag{
+						Name:  "output",
+						Usage: "write the schema to this file instead of stdout",
+					},
+				},
+				Action: export,
+			},
+			{
+				Name: "diff",
+				Usage: "compares the schema of the entries with the table, or a BigQuery JSON schema file, and prints the " +
+					"additive and breaking changes, it fails when there are breaking changes",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "file",
+						Usage: "BigQuery JSON schema file to compare with instead of the table, e.g. the export of a release",
+					},
+				},
+				Action: diff,
+			},
+		},
+		Action: plan,
+	}
+
+	err := app.Run(os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// plan prints the pending migrations of the table.
+func plan(c *cli.Context) error {
+	bq, err := pkg.NewBigQuery(c.String("project"))
+	if err != nil {
+		return err
+	}
+	//nolint
+	defer bq.Close()
+	p, err := bq.PlanMigrations(c.String("dataset"), c.String("table"))
+	if err != nil {
+		return err
+	}
+	fmt.Print(p)
+	return nil
+}
+
+// apply prints the pending migrations of the table and applies them.
+func apply(c *cli.Context) error {
+	bq, err := pkg.NewBigQuery(c.String("project"))
+	if err != nil {
+		return err
+	}
+	//nolint
+	defer bq.Close()
+	p, err := bq.PlanMigrations(c.String("dataset"), c.String("table"))
 	if err != nil {
-		panic(err)
+		return err
+	}
+	fmt.Print(p)
+	if err := bq.ApplyMigrations(p); err != nil {
+		return err
+	}
+	log.Printf("%s.%s migrated to version %d\n", p.Dataset, p.Table, p.Version+len(p.Steps))
+	return nil
+}
+
+// export prints the schema of the entries in the format of the format flag.
+func export(c *cli.Context) error {
+	var data []byte
+	var err error
+	switch c.String("format") {
+	case "bigquery":
+		data, err = pkg.EntryBigQuerySchema()
+	case "jsonschema":
+		data, err = pkg.EntryJSONSchema()
+	case "avro":
+		data, err = pkg.EntryAvroSchema()
+	default:
+		return fmt.Errorf("unknown format %q, one of bigquery, jsonschema, avro", c.String("format"))
+	}
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if output := c.String("output"); output != "" {
+		return os.WriteFile(output, data, 0o644)
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// diff prints the changes between the table, or the schema file, and the schema of the entries.
+func diff(c *cli.Context) error {
+	var schema bigquery.Schema
+	if file := c.String("file"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if schema, err = bigquery.SchemaFromJSON(data); err != nil {
+			return fmt.Errorf("failed to parse %s %w", file, err)
+		}
+	} else {
+		bq, err := pkg.NewBigQuery(c.String("project"))
+		if err != nil {
+			return err
+		}
+		//nolint
+		defer bq.Close()
+		if schema, err = bq.TableSchema(c.String("dataset"), c.String("table")); err != nil {
+			return err
+		}
+	}
+	breaking := 0
+	for _, change := range pkg.DiffEntrySchema(schema) {
+		kind := "additive"
+		if change.Breaking() {
+			kind = "breaking"
+			breaking++
+		}
+		fmt.Printf("%-8s %s\n", kind, change)
+	}
+	if breaking > 0 {
+		return fmt.Errorf("%d breaking changes", breaking)
 	}
+	return nil
 }
@@ -0,0 +1,126 @@
+// This package finds the rows that were decoded by an older version of a kind's decoder
+// and creates k8s jobs that reprocess just those entries with the current decoder.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/naveensrinivasan/rekor-phren/pkg"
+	"github.com/naveensrinivasan/rekor-phren/pkg/k8s"
+
+	// auth provider gcp
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+)
+
+// chunkSize is the maximum number of entries reprocessed by a single job.
+const chunkSize = 50000
+
+func main() {
+	app := &cli.App{
+		Name:  "stale-entries",
+		Usage: "stale-entries --kind <kind> schedules the re-decoding of rows produced by an older decoder",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "project",
+				Usage:   "GCP project of the dataset, detected from the credentials when it is not set",
+				EnvVars: []string{"PHREN_PROJECT", "GOOGLE_CLOUD_PROJECT"},
+			},
+			&cli.StringFlag{
+				Name:    "dataset",
+				Usage:   "Name of the dataset",
+				Value:   "phren",
+				EnvVars: []string{"DATASET"},
+			},
+			&cli.StringFlag{
+				Name:    "table",
+				Usage:   "Name of the table",
+				Value:   "phren",
+				EnvVars: []string{"TABLE"},
+			},
+			&cli.StringFlag{
+				Name:    "bucket",
+				Usage:   "GCS bucket the raw entries are archived in, the default bucket of rekor-phren when it is not set",
+				EnvVars: []string{"PHREN_BUCKET"},
+			},
+			&cli.StringFlag{
+				Name:    "rekor-url",
+				Usage:   "URL to the rekor server",
+				Value:   "http://rekor-sigstore-server.sigstore.svc.cluster.local",
+				EnvVars: []string{"REKOR_URL"},
+			},
+			&cli.StringFlag{
+				Name:     "kind",
+				Usage:    "kind whose decoder changed, e.g. intoto",
+				Required: true,
+				EnvVars:  []string{"KIND"},
+			},
+			&cli.IntFlag{
+				Name:    "version",
+				Usage:   "rows decoded by a version lower than this are stale, defaults to the current decoder version",
+				EnvVars: []string{"DECODER_VERSION"},
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "only print the stale ranges",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			dataset := c.String("dataset")
+			tableName := c.String("table")
+			kind := c.String("kind")
+			version := c.Int("version")
+			if version == 0 {
+				var ok bool
+				version, ok = pkg.DecoderVersions[kind]
+				if !ok {
+					return fmt.Errorf("unknown kind %q", kind)
+				}
+			}
+
+			bq, err := pkg.NewBigQuery(c.String("project"))
+			if err != nil {
+				return err
+			}
+			//nolint
+			defer bq.Close()
+			stale, err := bq.GetStaleEntries(dataset, tableName, kind, version)
+			if err != nil {
+				return err
+			}
+			if len(stale) == 0 {
+				log.Printf("No %s entries older than decoder version %d found\n", kind, version)
+				return nil
+			}
+			ranges := k8s.Ranges(stale, chunkSize)
+			log.Printf("found %d stale %s entries in %d ranges\n", len(stale), kind, len(ranges))
+			if c.Bool("dry-run") {
+				for _, r := range ranges {
+					fmt.Printf("%d-%d\n", r.From, r.To)
+				}
+				return nil
+			}
+
+			hostname := c.String("rekor-url")
+			k, err := k8s.New(bq, pkg.NewTLog(hostname), hostname, c.String("project"), dataset, tableName,
+				c.String("bucket"), "default")
+			if err != nil {
+				return fmt.Errorf("error creating k8s client: %w", err)
+			}
+			for _, r := range ranges {
+				if err := k.CreateReprocessJob(r, kind); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	err := app.Run(os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"sync"
@@ -15,12 +17,31 @@ var (
 	retry             = 5
 	e                 *log.Logger
 	tableName         = "rekor_test"
-	bucket            pkg.Bucket
 	rekor             pkg.TLog
 	url               string
 	bucketName        = "openssf-rekor-test"
 	dataset           = "rekor_test"
+	project           string
+	repository        *pkg.BigQuery
 	startFromLeftOver = false
+	sinkNames         = cli.NewStringSlice("bigquery", "gcs")
+	outputDir         string
+	outputOptions     pkg.DirectoryOptions
+	ndjsonOptions     = pkg.NDJSONOptions{MaxEntries: 100000, MaxBytes: 256 << 20}
+	parquetOptions    = pkg.ParquetOptions{RowGroupSize: 10000, MaxOpenFiles: 16}
+	sqlitePath        string
+	postgresOptions   = pkg.PostgresOptions{BatchSize: 500}
+	s3Options         pkg.S3Options
+	kafkaOptions      pkg.StreamOptions
+	natsOptions       pkg.StreamOptions
+	publishRetries    = 5
+	webhookOptions    = pkg.WebhookOptions{MaxEntries: 100, MaxLatency: 5 * time.Second}
+	searchOptions     = pkg.SearchOptions{IndexPrefix: "rekor", BatchSize: 500}
+	bigQueryOptions   = pkg.BigQueryOptions{BatchSize: 500, FlushInterval: 10 * time.Second}
+	storageWrite      bool
+	storageWriteState string
+	loadOptions       = pkg.LoadOptions{Prefix: "staging", MaxEntries: 50000}
+	loadJobs          bool
 )
 
 func main() {
@@ -96,6 +117,26 @@ func handleCLI(start int64, end int64, concurrency int) *cli.App {
 					"PHREN_DATASET",
 				},
 			},
+			&cli.StringFlag{
+				Name:        "bigquery-project",
+				Usage:       "GCP project of the BigQuery dataset, detected from the credentials when it is not set",
+				Destination: &project,
+				EnvVars:     []string{"PHREN_PROJECT", "GOOGLE_CLOUD_PROJECT"},
+			},
+			&cli.IntFlag{
+				Name:        "bigquery-batch-size",
+				Usage:       "number of rows buffered before they are inserted into BigQuery",
+				Value:       bigQueryOptions.BatchSize,
+				Destination: &bigQueryOptions.BatchSize,
+				EnvVars:     []string{"PHREN_BIGQUERY_BATCH_SIZE"},
+			},
+			&cli.DurationFlag{
+				Name:        "bigquery-flush-interval",
+				Usage:       "time after which buffered rows are inserted into BigQuery even if the batch is not full",
+				Value:       bigQueryOptions.FlushInterval,
+				Destination: &bigQueryOptions.FlushInterval,
+				EnvVars:     []string{"PHREN_BIGQUERY_FLUSH_INTERVAL"},
+			},
 			&cli.StringFlag{
 				Name:        "gcs-bucket-name",
 				Aliases:     []string{"b"},
@@ -126,15 +167,328 @@ func handleCLI(start int64, end int64, concurrency int) *cli.App {
 					"PHREN_START_FROM_LEFT_OVER",
 				},
 			},
+			&cli.StringFlag{
+				Name:        "s3-bucket-name",
+				Usage:       "S3-compatible bucket to write the entries to, selects the s3 sink when --sink is not set",
+				Destination: &s3Options.Bucket,
+				EnvVars:     []string{"PHREN_S3_BUCKET"},
+			},
+			&cli.StringFlag{
+				Name:        "s3-endpoint",
+				Usage:       "S3-compatible endpoint, e.g. http://localhost:9000 for MinIO or <account>.r2.cloudflarestorage.com",
+				DefaultText: "s3.amazonaws.com",
+				Destination: &s3Options.Endpoint,
+				EnvVars:     []string{"PHREN_S3_ENDPOINT"},
+			},
+			&cli.StringFlag{
+				Name:        "s3-region",
+				Usage:       "region of the S3 bucket",
+				Destination: &s3Options.Region,
+				EnvVars:     []string{"PHREN_S3_REGION", "AWS_REGION"},
+			},
+			&cli.StringFlag{
+				Name:        "s3-access-key-id",
+				Usage:       "S3 access key, the AWS credential chain is used when it is not set",
+				Destination: &s3Options.AccessKeyID,
+				EnvVars:     []string{"PHREN_S3_ACCESS_KEY_ID"},
+			},
+			&cli.StringFlag{
+				Name:        "s3-secret-access-key",
+				Usage:       "S3 secret key",
+				Destination: &s3Options.SecretAccessKey,
+				EnvVars:     []string{"PHREN_S3_SECRET_ACCESS_KEY"},
+			},
+			&cli.StringFlag{
+				Name:        "s3-session-token",
+				Usage:       "S3 session token of temporary credentials",
+				Destination: &s3Options.SessionToken,
+				EnvVars:     []string{"PHREN_S3_SESSION_TOKEN"},
+			},
+			&cli.BoolFlag{
+				Name:        "s3-path-style",
+				Usage:       "address the bucket as <endpoint>/<bucket>, required by MinIO",
+				Destination: &s3Options.PathStyle,
+				EnvVars:     []string{"PHREN_S3_PATH_STYLE"},
+			},
 		},
 		Commands: []*cli.Command{
 			{
 				Name:        "update",
 				Usage:       "update -u <rekor url> -b <bucket name> -t <table name> -s <start> -e <end> -c <concurrency>",
 				Description: "This command updates the BigQuery table and the bucket with the rekor entries. ",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:        "sink",
+						Usage:       "where to write the entries, one or more of bigquery, gcs, s3, filesystem, ndjson, parquet, sqlite, postgres, kafka, nats, webhook, search",
+						Value:       sinkNames,
+						DefaultText: "bigquery, gcs",
+						Destination: sinkNames,
+						EnvVars:     []string{"PHREN_SINKS"},
+					},
+					&cli.BoolFlag{
+						Name:        "bigquery-write-api",
+						Usage:       "write to BigQuery through a committed Storage Write API stream with offsets derived from the log index instead of streaming inserts",
+						Destination: &storageWrite,
+						EnvVars:     []string{"PHREN_BIGQUERY_WRITE_API"},
+					},
+					&cli.StringFlag{
+						Name:        "bigquery-write-state",
+						Usage:       "file that keeps the open Storage Write API stream, a restarted job with the same file appends to the same stream; rows are only written exactly once when it is set",
+						Destination: &storageWriteState,
+						EnvVars:     []string{"PHREN_BIGQUERY_WRITE_STATE"},
+					},
+					&cli.BoolFlag{
+						Name:        "bigquery-load",
+						Usage:       "stage the entries as NDJSON files in the GCS bucket and load them with a load job instead of streaming inserts, for backfills",
+						Destination: &loadJobs,
+						EnvVars:     []string{"PHREN_BIGQUERY_LOAD"},
+					},
+					&cli.StringFlag{
+						Name:        "bigquery-staging-prefix",
+						Usage:       "prefix in the GCS bucket the files of --bigquery-load are staged in",
+						Value:       loadOptions.Prefix,
+						Destination: &loadOptions.Prefix,
+						EnvVars:     []string{"PHREN_BIGQUERY_STAGING_PREFIX"},
+					},
+					&cli.IntFlag{
+						Name:        "bigquery-staging-max-entries",
+						Usage:       "number of entries in a file staged by --bigquery-load",
+						Value:       loadOptions.MaxEntries,
+						Destination: &loadOptions.MaxEntries,
+						EnvVars:     []string{"PHREN_BIGQUERY_STAGING_MAX_ENTRIES"},
+					},
+					&cli.StringFlag{
+						Name:        "output-dir",
+						Usage:       "write the entries to this directory, selects the filesystem sink when --sink is not set",
+						Destination: &outputDir,
+						EnvVars:     []string{"PHREN_OUTPUT_DIR"},
+					},
+					&cli.BoolFlag{
+						Name:        "output-fanout",
+						Usage:       "nest the entry directories under <index/1000000>/<index/1000%1000> instead of the flat <index> layout of the bucket",
+						Destination: &outputOptions.FanOut,
+						EnvVars:     []string{"PHREN_OUTPUT_FANOUT"},
+					},
+					&cli.BoolFlag{
+						Name:        "output-fsync",
+						Usage:       "fsync every file written to the output directory",
+						Destination: &outputOptions.Fsync,
+						EnvVars:     []string{"PHREN_OUTPUT_FSYNC"},
+					},
+					&cli.StringFlag{
+						Name:        "ndjson-dir",
+						Usage:       "write the entries as rotated NDJSON files to this directory, selects the ndjson sink when --sink is not set",
+						Destination: &ndjsonOptions.Dir,
+						EnvVars:     []string{"PHREN_NDJSON_DIR"},
+					},
+					&cli.IntFlag{
+						Name:        "ndjson-max-entries",
+						Usage:       "rotate the NDJSON file after this many entries",
+						Value:       ndjsonOptions.MaxEntries,
+						Destination: &ndjsonOptions.MaxEntries,
+						EnvVars:     []string{"PHREN_NDJSON_MAX_ENTRIES"},
+					},
+					&cli.Int64Flag{
+						Name:        "ndjson-max-bytes",
+						Usage:       "rotate the NDJSON file after this many uncompressed bytes",
+						Value:       ndjsonOptions.MaxBytes,
+						Destination: &ndjsonOptions.MaxBytes,
+						EnvVars:     []string{"PHREN_NDJSON_MAX_BYTES"},
+					},
+					&cli.StringFlag{
+						Name:        "ndjson-compression",
+						Usage:       "compress the NDJSON files with gzip or zstd",
+						Destination: &ndjsonOptions.Compression,
+						EnvVars:     []string{"PHREN_NDJSON_COMPRESSION"},
+					},
+					&cli.StringFlag{
+						Name:        "sqlite-db",
+						Usage:       "write the entries to this SQLite database and resume from it, selects the sqlite sink when --sink is not set",
+						Destination: &sqlitePath,
+						EnvVars:     []string{"PHREN_SQLITE_DB"},
+					},
+					&cli.StringFlag{
+						Name:        "parquet-dir",
+						Usage:       "write the entries as Parquet files partitioned by integrated date, selects the parquet sink when --sink is not set",
+						Destination: &parquetOptions.Dir,
+						EnvVars:     []string{"PHREN_PARQUET_DIR"},
+					},
+					&cli.IntFlag{
+						Name:        "parquet-row-group-size",
+						Usage:       "number of entries in a Parquet row group",
+						Value:       parquetOptions.RowGroupSize,
+						Destination: &parquetOptions.RowGroupSize,
+						EnvVars:     []string{"PHREN_PARQUET_ROW_GROUP_SIZE"},
+					},
+					&cli.IntFlag{
+						Name:        "parquet-max-open-files",
+						Usage:       "number of integrated dates whose Parquet files are kept open, the least recently written one is closed first",
+						Value:       parquetOptions.MaxOpenFiles,
+						Destination: &parquetOptions.MaxOpenFiles,
+						EnvVars:     []string{"PHREN_PARQUET_MAX_OPEN_FILES"},
+					},
+					&cli.StringFlag{
+						Name:        "postgres-url",
+						Usage:       "write the entries to this PostgreSQL database and resume from it, selects the postgres sink when --sink is not set",
+						Destination: &postgresOptions.URL,
+						EnvVars:     []string{"PHREN_POSTGRES_URL"},
+					},
+					&cli.StringFlag{
+						Name:        "postgres-table",
+						Usage:       "name of the PostgreSQL entries table",
+						Value:       "entries",
+						Destination: &postgresOptions.Table,
+						EnvVars:     []string{"PHREN_POSTGRES_TABLE"},
+					},
+					&cli.IntFlag{
+						Name:        "postgres-batch-size",
+						Usage:       "number of entries copied to PostgreSQL at once",
+						Value:       postgresOptions.BatchSize,
+						Destination: &postgresOptions.BatchSize,
+						EnvVars:     []string{"PHREN_POSTGRES_BATCH_SIZE"},
+					},
+					&cli.StringSliceFlag{
+						Name:    "kafka-brokers",
+						Usage:   "publish the entries to these Kafka brokers, selects the kafka sink when --sink is not set",
+						EnvVars: []string{"PHREN_KAFKA_BROKERS"},
+					},
+					&cli.StringFlag{
+						Name:        "kafka-topic",
+						Usage:       "Kafka topic the entries are published to",
+						Value:       "rekor-entries",
+						Destination: &kafkaOptions.Topic,
+						EnvVars:     []string{"PHREN_KAFKA_TOPIC"},
+					},
+					&cli.StringSliceFlag{
+						Name:    "nats-url",
+						Usage:   "publish the entries to these NATS servers, selects the nats sink when --sink is not set",
+						EnvVars: []string{"PHREN_NATS_URL"},
+					},
+					&cli.StringFlag{
+						Name:        "nats-subject",
+						Usage:       "JetStream subject the entries are published to, it must be captured by a stream",
+						Value:       "rekor.entries",
+						Destination: &natsOptions.Topic,
+						EnvVars:     []string{"PHREN_NATS_SUBJECT"},
+					},
+					&cli.IntFlag{
+						Name:        "publish-retries",
+						Usage:       "number of times a Kafka, NATS, webhook or search publish that was not acknowledged is retried",
+						Value:       publishRetries,
+						Destination: &publishRetries,
+						EnvVars:     []string{"PHREN_PUBLISH_RETRIES"},
+					},
+					&cli.StringSliceFlag{
+						Name:    "webhook-url",
+						Usage:   "POST batches of entries to these URLs, selects the webhook sink when --sink is not set",
+						EnvVars: []string{"PHREN_WEBHOOK_URL"},
+					},
+					&cli.StringFlag{
+						Name:        "webhook-secret",
+						Usage:       "HMAC-SHA256 key the webhook requests are signed with",
+						Destination: &webhookOptions.Secret,
+						EnvVars:     []string{"PHREN_WEBHOOK_SECRET"},
+					},
+					&cli.IntFlag{
+						Name:        "webhook-max-entries",
+						Usage:       "number of entries after which a webhook batch is sent",
+						Value:       webhookOptions.MaxEntries,
+						Destination: &webhookOptions.MaxEntries,
+						EnvVars:     []string{"PHREN_WEBHOOK_MAX_ENTRIES"},
+					},
+					&cli.DurationFlag{
+						Name:        "webhook-max-latency",
+						Usage:       "time after which a webhook batch is sent even if it is not full",
+						Value:       webhookOptions.MaxLatency,
+						Destination: &webhookOptions.MaxLatency,
+						EnvVars:     []string{"PHREN_WEBHOOK_MAX_LATENCY"},
+					},
+					&cli.StringFlag{
+						Name:        "search-url",
+						Usage:       "bulk-index the entries into this OpenSearch or Elasticsearch cluster, selects the search sink when --sink is not set",
+						Destination: &searchOptions.URL,
+						EnvVars:     []string{"PHREN_SEARCH_URL"},
+					},
+					&cli.StringFlag{
+						Name:        "search-username",
+						Usage:       "basic authentication user of the search cluster",
+						Destination: &searchOptions.Username,
+						EnvVars:     []string{"PHREN_SEARCH_USERNAME"},
+					},
+					&cli.StringFlag{
+						Name:        "search-password",
+						Usage:       "basic authentication password of the search cluster",
+						Destination: &searchOptions.Password,
+						EnvVars:     []string{"PHREN_SEARCH_PASSWORD"},
+					},
+					&cli.StringFlag{
+						Name:        "search-index-prefix",
+						Usage:       "prefix of the monthly indexes <prefix>-YYYY.MM",
+						Value:       searchOptions.IndexPrefix,
+						Destination: &searchOptions.IndexPrefix,
+						EnvVars:     []string{"PHREN_SEARCH_INDEX_PREFIX"},
+					},
+					&cli.IntFlag{
+						Name:        "search-batch-size",
+						Usage:       "number of entries in a bulk request",
+						Value:       searchOptions.BatchSize,
+						Destination: &searchOptions.BatchSize,
+						EnvVars:     []string{"PHREN_SEARCH_BATCH_SIZE"},
+					},
+				},
 				Action: func(c *cli.Context) error {
+					kafkaOptions.Brokers = c.StringSlice("kafka-brokers")
+					natsOptions.Brokers = c.StringSlice("nats-url")
+					kafkaOptions.Retries, natsOptions.Retries = publishRetries, publishRetries
+					webhookOptions.URLs = c.StringSlice("webhook-url")
+					webhookOptions.Retries = publishRetries
+					searchOptions.Retries = publishRetries
+					if !c.IsSet("sink") && (outputDir != "" || ndjsonOptions.Dir != "" || parquetOptions.Dir != "" || sqlitePath != "" ||
+						postgresOptions.URL != "" || s3Options.Bucket != "" || len(kafkaOptions.Brokers) > 0 ||
+						len(natsOptions.Brokers) > 0 || len(webhookOptions.URLs) > 0 || searchOptions.URL != "") {
+						var names []string
+						if s3Options.Bucket != "" {
+							names = append(names, "s3")
+						}
+						if outputDir != "" {
+							names = append(names, "filesystem")
+						}
+						if ndjsonOptions.Dir != "" {
+							names = append(names, "ndjson")
+						}
+						if parquetOptions.Dir != "" {
+							names = append(names, "parquet")
+						}
+						if sqlitePath != "" {
+							names = append(names, "sqlite")
+						}
+						if postgresOptions.URL != "" {
+							names = append(names, "postgres")
+						}
+						if len(kafkaOptions.Brokers) > 0 {
+							names = append(names, "kafka")
+						}
+						if len(natsOptions.Brokers) > 0 {
+							names = append(names, "nats")
+						}
+						if len(webhookOptions.URLs) > 0 {
+							names = append(names, "webhook")
+						}
+						if searchOptions.URL != "" {
+							names = append(names, "search")
+						}
+						sinkNames = cli.NewStringSlice(names...)
+					}
 					if startFromLeftOver {
-						lastentry, err := pkg.New().GetLastEntry(dataset, tableName)
+						state, err := newState()
+						if err != nil {
+							return err
+						}
+						// the BigQuery repository is shared with the sinks and closed by the app.
+						if closer, ok := state.(io.Closer); ok && state != pkg.Phren(repository) {
+							defer closer.Close()
+						}
+						lastentry, err := state.GetLastEntry(dataset, tableName)
 						if err != nil {
 							e.Printf("failed to get last entry %v", err)
 							return err
@@ -151,11 +505,64 @@ func handleCLI(start int64, end int64, concurrency int) *cli.App {
 						}
 					}
 					log.Println("start", start, "end", end, "concurrency", concurrency)
-					update(end, concurrency, start)
-					return nil
+					return update(end, concurrency, start)
+				},
+			},
+			{
+				Name:        "reprocess",
+				Usage:       "reprocess -b <bucket name> -t <table name> -s <start> -e <end> [--source-dir <dir>] [--kind <kind>]",
+				Description: "This command re-decodes the archived raw rekor entries with the current parsers and rewrites the BigQuery rows and entry.json objects.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "source-dir",
+						Usage:   "read the raw entries from a local directory instead of the bucket",
+						EnvVars: []string{"PHREN_SOURCE_DIR"},
+					},
+					&cli.BoolFlag{
+						Name:    "source-fanout",
+						Usage:   "the source directory was written with --output-fanout",
+						EnvVars: []string{"PHREN_SOURCE_FANOUT"},
+					},
+					&cli.StringSliceFlag{
+						Name:    "kind",
+						Usage:   "only reprocess entries of the given kinds, e.g. intoto",
+						EnvVars: []string{"PHREN_KIND"},
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if end == 0 {
+						return fmt.Errorf("end-index is required")
+					}
+					var archive pkg.Bucket
+					var err error
+					if dir := c.String("source-dir"); dir != "" {
+						archive, err = pkg.NewDirectory(dir, pkg.DirectoryOptions{FanOut: c.Bool("source-fanout")})
+					} else if s3Options.Bucket != "" {
+						archive, err = pkg.NewS3Bucket(s3Options)
+					} else {
+						archive, err = pkg.NewBucket(bucketName)
+					}
+					if err != nil {
+						return err
+					}
+					if closer, ok := archive.(io.Closer); ok {
+						defer closer.Close()
+					}
+					bq, err := bigQuery()
+					if err != nil {
+						return err
+					}
+					log.Println("reprocess start", start, "end", end, "concurrency", concurrency)
+					return reprocess(archive, bq, pkg.NewTLog(url), start, end, concurrency, c.StringSlice("kind"))
 				},
 			},
 		},
+		After: func(c *cli.Context) error {
+			if repository != nil {
+				return repository.Close()
+			}
+			return nil
+		},
 	}
 	return app
 }
@@ -174,9 +581,9 @@ func update(end int64, concurrency int, start int64) error {
 		}
 	}
 
-	bucket, err := pkg.NewBucket(bucketName)
+	sinks, err := newSinks(sinkNames.Value(), start)
 	if err != nil {
-		return fmt.Errorf("failed to create bucket %w", err)
+		return err
 	}
 	wg := sync.WaitGroup{}
 	wg.Add(concurrency)
@@ -186,7 +593,7 @@ func update(end int64, concurrency int, start int64) error {
 	for i := 0; i < concurrency; i++ {
 		go func() {
 			for i := range ch {
-				GetRekorEntry(rekor, i, tableName, bucket)
+				GetRekorEntry(rekor, i, sinks)
 			}
 			wg.Done()
 		}()
@@ -201,45 +608,169 @@ func update(end int64, concurrency int, start int64) error {
 	}()
 
 	wg.Wait()
-	return nil
+	// the sinks buffer entries, an error writing them makes the job fail.
+	var errs []error
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close sink %w", err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
-// GetRekorEntry gets the rekor entry and updates the table
-func GetRekorEntry(rekor pkg.TLog, i int64, tableName string, bucket pkg.Bucket) {
-	var wg sync.WaitGroup
-	data, err := rekor.Entry(i)
-	if retry > 0 && err != nil {
-		// retrying once more
-		data, err = rekor.Entry(i)
-		if err != nil {
-			handleErr(err)
+// newSinks creates the sinks with the given names, start is the first log index that is written.
+func newSinks(names []string, start int64) ([]pkg.Sink, error) {
+	var sinks []pkg.Sink //nolint:prealloc
+	for _, name := range names {
+		var sink pkg.Sink
+		var err error
+		switch name {
+		case "bigquery":
+			var bq *pkg.BigQuery
+			if storageWrite && loadJobs {
+				err = fmt.Errorf("--bigquery-write-api and --bigquery-load cannot be used together")
+			} else if bq, err = bigQuery(); err != nil {
+				break
+			} else if loadJobs {
+				opts := loadOptions
+				opts.Dataset, opts.Table, opts.Bucket = dataset, tableName, bucketName
+				sink, err = pkg.NewBigQueryLoadSink(bq, opts)
+			} else if storageWrite {
+				sink, err = pkg.NewStorageWriteSink(bq, pkg.StorageWriteOptions{Dataset: dataset, Table: tableName,
+					Start: start, StateFile: storageWriteState, BatchSize: bigQueryOptions.BatchSize,
+					OnGap: func(first, last int64) {
+						e.Printf("log indexes %d-%d are missing from the Storage Write API stream, skipping", first, last)
+					}})
+			} else {
+				sink, err = newBigQuerySink()
+			}
+		case "gcs":
+			var b pkg.Bucket
+			b, err = pkg.NewBucket(bucketName)
+			if err == nil {
+				sink, err = pkg.NewBucketSink(b)
+			}
+		case "s3":
+			var b pkg.Bucket
+			b, err = pkg.NewS3Bucket(s3Options)
+			if err == nil {
+				sink, err = pkg.NewBucketSink(b)
+			}
+		case "filesystem":
+			sink, err = pkg.NewFilesystemSink(outputDir, outputOptions)
+		case "ndjson":
+			sink, err = pkg.NewNDJSONSink(ndjsonOptions)
+		case "parquet":
+			sink, err = pkg.NewParquetSink(parquetOptions)
+		case "sqlite":
+			sink, err = pkg.NewSQLiteStore(sqlitePath)
+		case "postgres":
+			sink, err = pkg.NewPostgresStore(postgresOptions)
+		case "kafka":
+			sink, err = pkg.NewKafkaSink(kafkaOptions)
+		case "nats":
+			sink, err = pkg.NewNATSSink(natsOptions)
+		case "webhook":
+			sink, err = pkg.NewWebhookSink(webhookOptions)
+		case "search":
+			sink, err = pkg.NewSearchSink(searchOptions)
+		default:
+			err = fmt.Errorf("unknown sink %q", name)
 		}
-	}
-	wg.Add(2)
-	go func(i int64) {
-		defer wg.Done()
-		err := pkg.Insert(data, dataset, tableName)
 		if err != nil {
-			handleErr(fmt.Errorf("failed to insert entry %d %w", i, err))
+			return nil, fmt.Errorf("failed to create %s sink %w", name, err)
 		}
-		time.Sleep(5 * time.Second)
-		err = pkg.Insert(data, dataset, tableName)
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) == 0 {
+		return nil, fmt.Errorf("at least one sink is required")
+	}
+	return sinks, nil
+}
+
+// bigQuery returns the BigQuery repository of the project flag. It is created on first use and shared by
+// the sinks and the state, the app closes it after the command.
+func bigQuery() (*pkg.BigQuery, error) {
+	if repository == nil {
+		bq, err := pkg.NewBigQuery(project)
 		if err != nil {
-			handleErr(fmt.Errorf("failed to insert entry %d %w", i, err))
+			return nil, err
 		}
-	}(i)
-	go func(i int64) {
-		defer wg.Done()
-		err := bucket.UpdateBucket(data)
+		repository = bq
+	}
+	return repository, nil
+}
+
+// newBigQuerySink returns the BigQuery sink of the dataset and table flags.
+func newBigQuerySink() (pkg.Sink, error) {
+	bq, err := bigQuery()
+	if err != nil {
+		return nil, err
+	}
+	opts := bigQueryOptions
+	opts.Dataset, opts.Table = dataset, tableName
+	return pkg.NewBigQuerySink(bq, opts)
+}
+
+// newState returns the backend that knows the last entry written, the SQLite or PostgreSQL database when
+// it is set and BigQuery otherwise.
+func newState() (pkg.Phren, error) {
+	if sqlitePath != "" {
+		store, err := pkg.NewSQLiteStore(sqlitePath)
 		if err != nil {
-			handleErr(fmt.Errorf("failed to update bucket %d %w", i, err))
+			return nil, fmt.Errorf("failed to open sqlite database %w", err)
 		}
-		time.Sleep(5 * time.Second)
-		err = bucket.UpdateBucket(data)
+		return store, nil
+	}
+	if postgresOptions.URL != "" {
+		store, err := pkg.NewPostgresStore(postgresOptions)
 		if err != nil {
-			handleErr(fmt.Errorf("failed to update bucket %d %w", i, err))
+			return nil, fmt.Errorf("failed to open postgres database %w", err)
 		}
-	}(i)
+		return store, nil
+	}
+	bq, err := bigQuery()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the bigquery client %w", err)
+	}
+	return bq, nil
+}
+
+// GetRekorEntry gets the rekor entry and writes it to the sinks
+func GetRekorEntry(rekor pkg.TLog, i int64, sinks []pkg.Sink) {
+	var wg sync.WaitGroup
+	raw, err := rekor.RawEntry(i)
+	if retry > 0 && err != nil {
+		// retrying once more
+		raw, err = rekor.RawEntry(i)
+	}
+	if err != nil {
+		// don't insert an empty entry, the index will be picked up as missing.
+		handleErr(fmt.Errorf("failed to get entry %d %w", i, err))
+		return
+	}
+	data, err := pkg.DecodeEntry(raw)
+	if err != nil {
+		handleErr(fmt.Errorf("failed to decode entry %d %w", i, err))
+		return
+	}
+	if data.ParseError != "" {
+		e.Printf("failed to decode entry %d, storing with parse error: %s", i, data.ParseError)
+	}
+	wg.Add(len(sinks))
+	for _, sink := range sinks {
+		go func(i int64, sink pkg.Sink) {
+			defer wg.Done()
+			if rs, ok := sink.(pkg.RawSink); ok {
+				if err := rs.WriteRaw(i, raw); err != nil {
+					handleErr(fmt.Errorf("failed to write raw entry %d %w", i, err))
+				}
+			}
+			if err := sink.Write(data); err != nil {
+				handleErr(fmt.Errorf("failed to write entry %d %w", i, err))
+			}
+		}(i, sink)
+	}
 	if i%1000 == 0 {
 		fmt.Println("Finished", i)
 	}
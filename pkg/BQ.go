@@ -1,78 +1,91 @@
 package pkg
 
 import (
+	"bytes"
 	"cloud.google.com/go/bigquery"
 	"context"
 	"errors"
 	"fmt"
 	"google.golang.org/api/iterator"
+	"strings"
+	"time"
 )
 
 type Phren interface {
 	GetLastEntry(dataset, table string) (int64, error)
+	GetMissingEntries(dataset, table string) ([]int64, error)
 }
-type phren struct {
+
+// BigQuery is the repository of the rekor tables in a GCP project. It holds a single client that is shared
+// by the queries and the BigQuery sinks.
+type BigQuery struct {
+	client *bigquery.Client
+}
+
+// NewBigQuery returns the repository of the project, the project ID is detected from the credentials
+// when it is empty.
+func NewBigQuery(project string) (*BigQuery, error) {
+	if project == "" {
+		project = bigquery.DetectProjectID
+	}
+	client, err := bigquery.NewClient(context.Background(), project)
+	if err != nil {
+		return nil, fmt.Errorf("bigquery.NewClient: %w", err)
+	}
+	return &BigQuery{client: client}, nil
+}
+
+// Project returns the ID of the project, the detected one if it was not set.
+func (b *BigQuery) Project() string {
+	return b.client.Project()
+}
+
+// Close closes the client.
+func (b *BigQuery) Close() error {
+	return b.client.Close()
 }
 
-func New() Phren {
-	return &phren{}
+// tableRef returns the quoted fully qualified name of the table for queries.
+func (b *BigQuery) tableRef(dataset, table string) string {
+	return fmt.Sprintf("`%s.%s.%s`", b.Project(), dataset, table)
 }
 
-// CreateOrUpdateSchema creates a new table in BigQuery. The func detects the project ID from the credentials.
-func CreateOrUpdateSchema(entry Entry, dataset, table string) error {
+// CreateOrUpdateSchema creates the table in BigQuery, or updates the schema of an existing one. It does not
+// record a migration, PlanMigrations and ApplyMigrations should be used for the entries table.
+func (b *BigQuery) CreateOrUpdateSchema(entry Entry, dataset, table string) error {
 	if dataset == "" {
 		return fmt.Errorf("dataset is required")
 	}
-	ctx := context.Background()
-	client, err := bigquery.NewClient(ctx, "openssf")
-	if err != nil {
-		return err
+	if table == "" {
+		return fmt.Errorf("table is required")
 	}
-	r := client.Dataset(dataset)
-	s, err := bigquery.InferSchema(entry)
+	ctx := context.Background()
+	s, err := tableSchema(entry)
 	if err != nil {
 		return err
 	}
-	s = s.Relax()
-	tables := r.Tables(context.Background())
-	isTableExists := false
-	for {
-		t, err := tables.Next()
-		if err != nil {
-			break
-		}
-		if t.TableID == dataset {
-			isTableExists = true
-			break
-		}
+	ref := b.client.Dataset(dataset).Table(table)
+	_, err = ref.Metadata(ctx)
+	if isNotFound(err) {
+		return ref.Create(ctx, &bigquery.TableMetadata{Schema: s, TimePartitioning: entryPartitioning,
+			Clustering: entryClustering})
 	}
-	if !isTableExists {
-		if err := r.Table(dataset).Create(context.Background(),
-			&bigquery.TableMetadata{Schema: s}); err != nil {
-			return err
-		}
-	} else {
-		if err := UpdateTableSchema(entry, dataset, table); err != nil {
-			return err
-		}
+	if err != nil {
+		return fmt.Errorf("Table.Metadata: %w", err)
 	}
-	return nil
+	return b.UpdateTableSchema(entry, dataset, table)
 }
-func UpdateTableSchema(entry Entry, dataset, table string) error {
+
+func (b *BigQuery) UpdateTableSchema(entry Entry, dataset, table string) error {
 	if dataset == "" {
 		return fmt.Errorf("dataset is required")
 	}
 	ctx := context.Background()
-	client, err := bigquery.NewClient(ctx, "openssf")
+	s, err := tableSchema(entry)
 	if err != nil {
 		return err
 	}
-	s, err := bigquery.InferSchema(entry)
-	if err != nil {
-		return err
-	}
-	s = s.Relax()
-	tableRef := client.Dataset(dataset).Table(table)
+	tableRef := b.client.Dataset(dataset).Table(table)
 	update := bigquery.TableMetadataToUpdate{
 		Schema: s,
 	}
@@ -81,33 +94,276 @@ func UpdateTableSchema(entry Entry, dataset, table string) error {
 	}
 	return nil
 }
-func Insert(entry Entry, dataset, table string) error {
+
+// PartitionTable migrates an unpartitioned table to the partitioning and clustering of new tables, tables that
+// are already partitioned are left alone. A copy job backs the table up to <table>_unpartitioned and the rows
+// are inserted into the new table <table>_partitioned with the derived columns filled in. It is created with the
+// schema of the table, CREATE TABLE AS SELECT would make every column NULLABLE. Only once it is complete the
+// table is deleted and a copy job puts <table>_partitioned in its place, a migration that failed continues
+// where it stopped when it is run again. The backup is kept and has to be dropped by hand. Writers have to be
+// stopped during the migration.
+func (b *BigQuery) PartitionTable(dataset, table string) error {
 	if dataset == "" {
 		return fmt.Errorf("dataset is required")
 	}
+	if table == "" {
+		return fmt.Errorf("table is required")
+	}
+	ctx := context.Background()
+	src := b.client.Dataset(dataset).Table(table)
+	partitioned := b.client.Dataset(dataset).Table(table + "_partitioned")
+	md, err := src.Metadata(ctx)
+	switch {
+	case isNotFound(err):
+		// an earlier run deleted the table after the partitioned table was complete.
+		return swapTable(partitioned, src)
+	case err != nil:
+		return fmt.Errorf("Table.Metadata: %w", err)
+	case md.TimePartitioning != nil:
+		return deleteTable(partitioned)
+	}
+	backup := b.client.Dataset(dataset).Table(table + "_unpartitioned")
+	copier := backup.CopierFrom(src)
+	// the backup of an earlier run is replaced, the table has not changed since.
+	copier.WriteDisposition = bigquery.WriteTruncate
+	if err := waitJob(copier.Run(ctx)); err != nil {
+		return fmt.Errorf("failed to copy %s to %s %w", table, backup.TableID, err)
+	}
+	if err := deleteTable(partitioned); err != nil {
+		return err
+	}
+	if err := partitioned.Create(ctx, &bigquery.TableMetadata{Schema: md.Schema, TimePartitioning: entryPartitioning,
+		Clustering: entryClustering, Description: md.Description, Labels: md.Labels}); err != nil {
+		return fmt.Errorf("failed to create %s %w", partitioned.TableID, err)
+	}
+	columns := make([]string, 0, len(md.Schema))
+	for _, field := range md.Schema {
+		columns = append(columns, field.Name)
+	}
+	q := b.client.Query(partitionQuery(b.tableRef(dataset, partitioned.TableID), b.tableRef(dataset, table), columns))
+	if err := waitJob(q.Run(ctx)); err != nil {
+		return fmt.Errorf("failed to insert the rows into %s %w", partitioned.TableID, err)
+	}
+	if err := src.Delete(ctx); err != nil {
+		return fmt.Errorf("Table.Delete: %w", err)
+	}
+	return swapTable(partitioned, src)
+}
+
+// swapTable copies the complete partitioned table to the deleted dst, with its description and labels, and
+// drops it. The copy job creates dst with the partitioning and clustering of partitioned.
+func swapTable(partitioned, dst *bigquery.Table) error {
 	ctx := context.Background()
-	client, err := bigquery.NewClient(ctx, "openssf")
+	md, err := partitioned.Metadata(ctx)
 	if err != nil {
+		return fmt.Errorf("neither %s nor %s can be read %w", dst.TableID, partitioned.TableID, err)
+	}
+	copier := dst.CopierFrom(partitioned)
+	copier.WriteDisposition = bigquery.WriteEmpty
+	if err := waitJob(copier.Run(ctx)); err != nil {
+		return fmt.Errorf("failed to copy %s to %s, the rows are in %s %w", partitioned.TableID, dst.TableID,
+			partitioned.TableID, err)
+	}
+	update := bigquery.TableMetadataToUpdate{Description: md.Description}
+	for name, value := range md.Labels {
+		update.SetLabel(name, value)
+	}
+	if _, err := dst.Update(ctx, update, ""); err != nil {
+		return fmt.Errorf("Table.Update: %w", err)
+	}
+	return deleteTable(partitioned)
+}
+
+// deleteTable deletes the table if it exists.
+func deleteTable(t *bigquery.Table) error {
+	if err := t.Delete(context.Background()); err != nil && !isNotFound(err) {
+		return fmt.Errorf("Table.Delete: %w", err)
+	}
+	return nil
+}
+
+// partitionQuery returns the query that inserts the columns of src into dst, computing the derived columns
+// the same way entrySaver does.
+func partitionQuery(dst, src string, columns []string) string {
+	derived := map[string]string{
+		"integratedat": "TIMESTAMP_SECONDS(IntegratedTime)",
+		"kindname":     "Kind.Kind",
+		"issuer": "COALESCE(" +
+			"NULLIF(Rekord.Signature.X509.IssuerOrganization, ''), NULLIF(Rekord.Signature.X509.IssuerCommonName, ''), " +
+			"NULLIF(HashedRekord.Signature.X509.IssuerOrganization, ''), NULLIF(HashedRekord.Signature.X509.IssuerCommonName, ''), " +
+			"NULLIF(Intoto.Signature.X509.IssuerOrganization, ''), NULLIF(Intoto.Signature.X509.IssuerCommonName, ''))",
+	}
+	values := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if expr, ok := derived[strings.ToLower(column)]; ok {
+			values = append(values, expr)
+		} else {
+			values = append(values, column)
+		}
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", dst, strings.Join(columns, ", "),
+		strings.Join(values, ", "), src)
+}
+
+// waitJob waits for the job to finish and returns its error.
+func waitJob(job *bigquery.Job, err error) error {
+	if err != nil {
+		return fmt.Errorf("Job.Run: %w", err)
+	}
+	status, err := job.Wait(context.Background())
+	if err != nil {
+		return fmt.Errorf("Job.Wait: %w", err)
+	}
+	return status.Err()
+}
+
+func (b *BigQuery) Insert(entry Entry, dataset, table string) error {
+	if dataset == "" {
+		return fmt.Errorf("dataset is required")
+	}
+	ctx := context.Background()
+	inserter := b.client.Dataset(dataset).Table(table).Inserter()
+	if err := inserter.Put(ctx, entrySaver{entry: entry}); err != nil {
 		return err
 	}
-	inserter := client.Dataset(dataset).Table(table).Inserter()
-	if err := inserter.Put(ctx, entry); err != nil {
+	return nil
+}
+
+// derivedSchema are the columns derived from the entry that the table is partitioned and clustered on.
+// Clustering columns have to be top level, so the kind is repeated outside of the Kind record.
+var derivedSchema = bigquery.Schema{
+	{Name: "IntegratedAt", Type: bigquery.TimestampFieldType, Description: "integrated time of the entry"},
+	{Name: "KindName", Type: bigquery.StringFieldType, Description: "kind of the entry, the same as Kind.Kind"},
+	{Name: "Issuer", Type: bigquery.StringFieldType, Description: "issuer organization, or common name, of the certificate"},
+}
+
+// entryPartitioning partitions the table by the day the entries were integrated, queries that filter on
+// IntegratedAt only scan the matching days.
+var entryPartitioning = &bigquery.TimePartitioning{Type: bigquery.DayPartitioningType, Field: "IntegratedAt"}
+
+// entryClustering sorts the rows of a partition by kind and issuer.
+var entryClustering = &bigquery.Clustering{Fields: []string{"KindName", "Issuer"}}
+
+// entrySchema is the schema the rows are saved with, the same one CreateOrUpdateSchema creates the table with.
+var entrySchema = mustInferSchema(Entry{})
+
+func mustInferSchema(entry Entry) bigquery.Schema {
+	s, err := tableSchema(entry)
+	if err != nil {
+		panic(fmt.Sprintf("bigquery.InferSchema: %v", err))
+	}
+	return s
+}
+
+// tableSchema returns the relaxed schema inferred from the entry followed by the derived columns.
+func tableSchema(entry Entry) (bigquery.Schema, error) {
+	s, err := bigquery.InferSchema(entry)
+	if err != nil {
+		return nil, err
+	}
+	return append(s.Relax(), derivedSchema...), nil
+}
+
+// EntryInsertID returns the insert id of the entry, BigQuery drops a streamed row whose insert id it has seen
+// recently. Rekor runs a log per shard, so the log id is part of it next to the log index.
+func EntryInsertID(e Entry) string {
+	return fmt.Sprintf("%s-%d", e.LogID, e.LogIndex)
+}
+
+// entrySaver is a bigquery.ValueSaver that saves the entry with its insert id, so that retried inserts of
+// the same entry are deduplicated.
+type entrySaver struct {
+	entry Entry
+}
+
+func (s entrySaver) Save() (map[string]bigquery.Value, string, error) {
+	saver := &bigquery.StructSaver{Schema: entrySchema, InsertID: EntryInsertID(s.entry), Struct: s.entry}
+	row, insertID, err := saver.Save()
+	if err != nil {
+		return nil, "", err
+	}
+	// the derived columns are not fields of the entry, the saver skips them.
+	row["IntegratedAt"] = time.Unix(int64(s.entry.IntegratedTime), 0).UTC()
+	if s.entry.Kind.Kind != "" {
+		row["KindName"] = s.entry.Kind.Kind
+	}
+	if sig, ok := signatureOf(s.entry); ok && sig.issuer() != "" {
+		row["Issuer"] = sig.issuer()
+	}
+	return row, insertID, nil
+}
+
+// entrySavers returns the value savers of the entries.
+func entrySavers(entries []Entry) []bigquery.ValueSaver {
+	savers := make([]bigquery.ValueSaver, 0, len(entries))
+	for _, entry := range entries {
+		savers = append(savers, entrySaver{entry: entry})
+	}
+	return savers
+}
+
+// ReplaceEntries replaces the rows of the entries with the given ones. The entries are loaded into a staging table
+// first and a single transaction then deletes the old rows and inserts the new ones, so a failure leaves the old
+// rows in place. Neither step is a streaming insert, the new rows are not dropped as duplicates of the old ones.
+// Rows still in the streaming buffer cannot be deleted and make the transaction fail.
+func (b *BigQuery) ReplaceEntries(dataset, table string, entries []Entry) error {
+	if dataset == "" {
+		return fmt.Errorf("dataset is required")
+	}
+	if table == "" {
+		return fmt.Errorf("table is required")
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	data, err := encodeLoadRows(entries)
+	if err != nil {
 		return err
 	}
+	ctx := context.Background()
+	staging := b.client.Dataset(dataset).Table(fmt.Sprintf("%s_replace_%d", table, time.Now().UnixNano()))
+	// the staging table expires on its own if it cannot be deleted.
+	if err := staging.Create(ctx, &bigquery.TableMetadata{Schema: entrySchema,
+		ExpirationTime: time.Now().Add(24 * time.Hour)}); err != nil {
+		return fmt.Errorf("Table.Create: %w", err)
+	}
+	//nolint
+	defer staging.Delete(ctx)
+	source := bigquery.NewReaderSource(bytes.NewReader(data))
+	source.SourceFormat = bigquery.JSON
+	source.Schema = entrySchema
+	loader := staging.LoaderFrom(source)
+	loader.WriteDisposition = bigquery.WriteTruncate
+	if err := waitJob(loader.Run(ctx)); err != nil {
+		return fmt.Errorf("failed to load the entries into %s %w", staging.TableID, err)
+	}
+	columns := make([]string, 0, len(entrySchema))
+	for _, field := range entrySchema {
+		columns = append(columns, field.Name)
+	}
+	q := b.client.Query(replaceQuery(b.tableRef(dataset, table), b.tableRef(dataset, staging.TableID), columns))
+	if err := waitJob(q.Run(ctx)); err != nil {
+		return fmt.Errorf("failed to replace the rows from %s %w", staging.TableID, err)
+	}
 	return nil
 }
 
+// replaceQuery returns the transaction that replaces the rows of dst with the rows of src that have the same
+// log index.
+func replaceQuery(dst, src string, columns []string) string {
+	return fmt.Sprintf(`BEGIN TRANSACTION;
+DELETE FROM %[1]s WHERE logindex IN (SELECT logindex FROM %[2]s);
+INSERT INTO %[1]s (%[3]s) SELECT %[3]s FROM %[2]s;
+COMMIT TRANSACTION;`, dst, src, strings.Join(columns, ", "))
+}
+
 // GetLastEntry returns the last entry from the BigQuery table.
-func (p phren) GetLastEntry(dataset, table string) (int64, error) {
+func (b *BigQuery) GetLastEntry(dataset, table string) (int64, error) {
 	if dataset == "" {
 		return 0, fmt.Errorf("dataset is required")
 	}
 	ctx := context.Background()
-	client, err := bigquery.NewClient(ctx, "openssf")
-	if err != nil {
-		return 0, fmt.Errorf("bigquery.NewClient: %w", err)
-	}
-	q := client.Query(fmt.Sprintf("SELECT max(logindex) FROM `openssf.%s.%s` LIMIT 1", dataset, table))
+	q := b.client.Query(fmt.Sprintf("SELECT max(logindex) FROM %s LIMIT 1", b.tableRef(dataset, table)))
 	it, err := q.Read(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("Query.Read: %w", err)
@@ -129,7 +385,7 @@ func (p phren) GetLastEntry(dataset, table string) (int64, error) {
 
 // GetMissingEntries returns the missing entries from the BigQuery table.
 // This will be used to fill the missing entries in the BigQuery table by rerunning the missing entries as cron job.
-func GetMissingEntries(dataset, table string) ([]int64, error) {
+func (b *BigQuery) GetMissingEntries(dataset, table string) ([]int64, error) {
 	query := "SELECT date FROM UNNEST(ARRAY_CONCAT(" +
 		"GENERATE_ARRAY(0, 1000000)" +
 		",GENERATE_ARRAY(1000001, 2000000)," +
@@ -140,18 +396,14 @@ func GetMissingEntries(dataset, table string) ([]int64, error) {
 		"GENERATE_ARRAY(6000001, 7000000)," +
 		"GENERATE_ARRAY(7000001, 8000000)," +
 		"GENERATE_ARRAY(8000001, 9000000)," +
-		"GENERATE_ARRAY(9000001, (select max(logindex) from `openssf.%s.%s`)))) " +
+		"GENERATE_ARRAY(9000001, (select max(logindex) from %[1]s)))) " +
 		"date EXCEPT DISTINCT " +
-		"SELECT logindex FROM `openssf.%s.%s`;"
+		"SELECT logindex FROM %[1]s;"
 	if dataset == "" {
 		return nil, fmt.Errorf("dataset is required")
 	}
 	ctx := context.Background()
-	client, err := bigquery.NewClient(ctx, "openssf")
-	if err != nil {
-		return nil, fmt.Errorf("bigquery.NewClient: %w", err)
-	}
-	q := client.Query(fmt.Sprintf(query, dataset, table, dataset, table))
+	q := b.client.Query(fmt.Sprintf(query, b.tableRef(dataset, table)))
 
 	it, err := q.Read(ctx)
 	if err != nil {
@@ -171,14 +423,43 @@ func GetMissingEntries(dataset, table string) ([]int64, error) {
 	}
 	return missing, nil
 }
-func getmaxlogindex(dataset, tableName string) (int64, error) {
-	max := int64(0)
+
+// GetStaleEntries returns the log indexes of the given kind that were decoded by a decoder older than version.
+// Rows written before decoder versions were recorded are treated as version 0.
+func (b *BigQuery) GetStaleEntries(dataset, table, kind string, version int) ([]int64, error) {
+	if dataset == "" {
+		return nil, fmt.Errorf("dataset is required")
+	}
 	ctx := context.Background()
-	client, err := bigquery.NewClient(ctx, "openssf")
+	q := b.client.Query(fmt.Sprintf("SELECT DISTINCT logindex FROM %s "+
+		"WHERE kind.kind = @kind AND IFNULL(decoderversion, 0) < @version ORDER BY logindex", b.tableRef(dataset, table)))
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "kind", Value: kind},
+		{Name: "version", Value: version},
+	}
+	it, err := q.Read(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("bigquery.NewClient: %w", err)
+		return nil, fmt.Errorf("Query.Read: %w", err)
+	}
+	var stale []int64
+	for {
+		var values []bigquery.Value
+		err := it.Next(&values)
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Iterator.Next: %w", err)
+		}
+		stale = append(stale, values[0].(int64))
 	}
-	q := client.Query(fmt.Sprintf("SELECT max(logindex) FROM `openssf.%s.%s` LIMIT 1", dataset, tableName))
+	return stale, nil
+}
+
+func (b *BigQuery) getmaxlogindex(dataset, tableName string) (int64, error) {
+	max := int64(0)
+	ctx := context.Background()
+	q := b.client.Query(fmt.Sprintf("SELECT max(logindex) FROM %s LIMIT 1", b.tableRef(dataset, tableName)))
 	it, err := q.Read(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("Query.Read: %w", err)
@@ -196,3 +477,75 @@ func getmaxlogindex(dataset, tableName string) (int64, error) {
 	}
 	return max, nil
 }
+
+// DuplicateRange is a range of log indexes, [Start, End], whose rows contain duplicates.
+type DuplicateRange struct {
+	Start      int64
+	End        int64
+	Duplicates int64
+}
+
+// GetDuplicateRanges splits the log indexes of the table into ranges of size and returns the ranges
+// that have more rows than distinct log indexes.
+func (b *BigQuery) GetDuplicateRanges(dataset, table string, size int64) ([]DuplicateRange, error) {
+	if dataset == "" {
+		return nil, fmt.Errorf("dataset is required")
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("range size must be positive")
+	}
+	ctx := context.Background()
+	q := b.client.Query(fmt.Sprintf("SELECT DIV(logindex, @size) AS chunk, COUNT(*) - COUNT(DISTINCT logindex) AS duplicates "+
+		"FROM %s GROUP BY chunk HAVING duplicates > 0 ORDER BY chunk", b.tableRef(dataset, table)))
+	q.Parameters = []bigquery.QueryParameter{{Name: "size", Value: size}}
+	it, err := q.Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Query.Read: %w", err)
+	}
+	var ranges []DuplicateRange
+	for {
+		var values []bigquery.Value
+		err := it.Next(&values)
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Iterator.Next: %w", err)
+		}
+		chunk := values[0].(int64)
+		ranges = append(ranges, DuplicateRange{Start: chunk * size, End: (chunk+1)*size - 1, Duplicates: values[1].(int64)})
+	}
+	return ranges, nil
+}
+
+// DeduplicateRange rewrites the rows of the log indexes between start and end, both inclusive, keeping the most
+// recently decoded row of every log index. The rows are replaced in a single transaction, rows still in the
+// streaming buffer cannot be deleted and make it fail, so it should be run on ranges older than an hour.
+func (b *BigQuery) DeduplicateRange(dataset, table string, start, end int64) error {
+	if dataset == "" {
+		return fmt.Errorf("dataset is required")
+	}
+	ctx := context.Background()
+	q := b.client.Query(fmt.Sprintf(`BEGIN TRANSACTION;
+CREATE TEMP TABLE deduplicated AS
+  SELECT * EXCEPT (row_number) FROM (
+    SELECT *, ROW_NUMBER() OVER (PARTITION BY logindex ORDER BY date DESC) AS row_number
+    FROM %[1]s WHERE logindex BETWEEN @start AND @end
+  ) WHERE row_number = 1;
+DELETE FROM %[1]s WHERE logindex BETWEEN @start AND @end;
+INSERT INTO %[1]s SELECT * FROM deduplicated;
+COMMIT TRANSACTION;`, b.tableRef(dataset, table)))
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "start", Value: start},
+		{Name: "end", Value: end},
+	}
+	job, err := q.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("Query.Run: %w", err)
+	}
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("Job.Wait: %w", err)
+	}
+	return status.Err()
+}
@@ -0,0 +1,92 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func TestEntrySaverDerivedColumns(t *testing.T) {
+	tests := []struct {
+		name       string
+		entry      Entry
+		wantKind   bigquery.Value
+		wantIssuer bigquery.Value
+	}{
+		{
+			name: "hashedrekord",
+			entry: Entry{IntegratedTime: 1672628645, Kind: Kind{Kind: "hashedrekord"}, HashedRekord: &Hashedrekord{
+				Signature: RekordSignature{X509: &X509{IssuerOrganization: "sigstore.dev", IssuerCommonName: "sigstore-intermediate"}}}},
+			wantKind:   "hashedrekord",
+			wantIssuer: "sigstore.dev",
+		},
+		{
+			name: "common name",
+			entry: Entry{IntegratedTime: 1672628645, Kind: Kind{Kind: "intoto"}, Intoto: &InToTo{
+				Signature: RekordSignature{X509: &X509{IssuerCommonName: "sigstore-intermediate"}}}},
+			wantKind:   "intoto",
+			wantIssuer: "sigstore-intermediate",
+		},
+		{
+			name:  "no issuer",
+			entry: Entry{IntegratedTime: 1672628645, Kind: Kind{Kind: "rekord"}, Rekord: &Rekord{}},
+			// nil values are left out of the row
+			wantKind: "rekord",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, _, err := entrySaver{entry: tt.entry}.Save()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC); row["IntegratedAt"] != want {
+				t.Errorf("IntegratedAt = %v, want %v", row["IntegratedAt"], want)
+			}
+			if row["KindName"] != tt.wantKind || row["Issuer"] != tt.wantIssuer {
+				t.Errorf("KindName = %v, Issuer = %v, want %v, %v", row["KindName"], row["Issuer"], tt.wantKind, tt.wantIssuer)
+			}
+		})
+	}
+}
+
+func TestEntrySchemaPartitioning(t *testing.T) {
+	columns := map[string]bigquery.FieldType{}
+	for _, field := range entrySchema {
+		columns[field.Name] = field.Type
+	}
+	if columns[entryPartitioning.Field] != bigquery.TimestampFieldType {
+		t.Errorf("partitioning column %s is %s", entryPartitioning.Field, columns[entryPartitioning.Field])
+	}
+	for _, field := range entryClustering.Fields {
+		if columns[field] != bigquery.StringFieldType {
+			t.Errorf("clustering column %s is %s", field, columns[field])
+		}
+	}
+}
+
+func TestPartitionQuery(t *testing.T) {
+	got := partitionQuery("`p.d.t_partitioned`", "`p.d.t`", []string{"IntegratedTime", "LogIndex", "IntegratedAt", "KindName"})
+	want := "INSERT INTO `p.d.t_partitioned` (IntegratedTime, LogIndex, IntegratedAt, KindName) " +
+		"SELECT IntegratedTime, LogIndex, TIMESTAMP_SECONDS(IntegratedTime), Kind.Kind FROM `p.d.t`"
+	if got != want {
+		t.Errorf("partitionQuery() = %s, want %s", got, want)
+	}
+	got = partitionQuery("`p.d.t_partitioned`", "`p.d.t`", []string{"Issuer"})
+	if !strings.Contains(got, "COALESCE(NULLIF(Rekord.Signature.X509.IssuerOrganization, '')") {
+		t.Errorf("partitionQuery() = %s", got)
+	}
+}
+
+func TestReplaceQuery(t *testing.T) {
+	got := replaceQuery("`p.d.t`", "`p.d.t_replace_1`", []string{"LogIndex", "Kind"})
+	want := "BEGIN TRANSACTION;\n" +
+		"DELETE FROM `p.d.t` WHERE logindex IN (SELECT logindex FROM `p.d.t_replace_1`);\n" +
+		"INSERT INTO `p.d.t` (LogIndex, Kind) SELECT LogIndex, Kind FROM `p.d.t_replace_1`;\n" +
+		"COMMIT TRANSACTION;"
+	if got != want {
+		t.Errorf("replaceQuery() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -1,22 +1,26 @@
 package pkg
 
 import (
-	"bytes"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"io"
 	"net/http"
-	"strings"
 	"time"
-
-	//nolint
-	"golang.org/x/crypto/openpgp"
 )
 
 const defaultHost = "https://rekor.sigstore.dev"
 
+// DecoderVersions holds the version of the decoder for each kind. Bump the version of a kind whenever
+// its decoding changes so that rows decoded by an older version can be found and re-decoded.
+var DecoderVersions = map[string]int{
+	"rekord":       1,
+	"hashedrekord": 1,
+	"intoto":       1,
+}
+
 // NewTLog creates an instance of the Tlog.
 func NewTLog(host string) TLog {
 	if host == "" {
@@ -61,32 +65,73 @@ func (t *tlog) Size() (int64, error) {
 
 // Entry returns the entry from the given tlogEntry.
 func (t *tlog) Entry(index int64) (Entry, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/api/v1/log/entries?logIndex=%d", t.host, index))
+	raw, err := t.RawEntry(index)
 	if err != nil {
 		return Entry{}, err
 	}
+	return DecodeEntry(raw)
+}
+
+// RawEntry returns the unmodified rekor API response for the given log index.
+func (t *tlog) RawEntry(index int64) ([]byte, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/log/entries?logIndex=%d", t.host, index))
+	if err != nil {
+		return nil, err
+	}
 	//nolint
 	defer resp.Body.Close()
-	m := make(map[string]tlogEntry)
-	err = json.NewDecoder(resp.Body).Decode(&m)
+	raw, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return Entry{}, fmt.Errorf("error decoding response body: %v %s", err, resp.Status)
+		return nil, fmt.Errorf("error reading response body: %v %s", err, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error getting entry %d: %s %s", index, resp.Status, raw)
 	}
+	return raw, nil
+}
 
+// DecodeEntry decodes a raw rekor API response, as returned by RawEntry, into an Entry.
+func DecodeEntry(raw []byte) (Entry, error) {
+	m := make(map[string]tlogEntry)
+	err := json.Unmarshal(raw, &m)
+	if err != nil {
+		return Entry{}, fmt.Errorf("error decoding response body: %v", err)
+	}
+	if len(m) == 0 {
+		return Entry{}, fmt.Errorf("no entry found in response")
+	}
 	var val tlogEntry
 	for _, v := range m {
 		val = v
 		break
 	}
+	return decodeEntry(val), nil
+}
+
+// decodeEntry decodes the body of the given tlogEntry into an Entry.
+// Decoding failures do not drop the entry, the envelope fields are kept and the failure is
+// recorded in ParseError along with the raw body so that every log index is represented.
+func decodeEntry(val tlogEntry) Entry {
 	value := getEntry(val)
-	f, err := base64.StdEncoding.DecodeString(val.Body)
+	value.Date = time.Now()
+	if err := decodeBody(val.Body, &value); err != nil {
+		value.ParseError = err.Error()
+		value.Body = val.Body
+	}
+	value.DecoderVersion = DecoderVersions[value.Kind.Kind]
+	return value
+}
+
+// decodeBody decodes the base64 encoded body into the kind specific fields of the entry.
+func decodeBody(body string, value *Entry) error {
+	f, err := base64.StdEncoding.DecodeString(body)
 	if err != nil {
-		return Entry{}, fmt.Errorf("error decoding base64: %v", err)
+		return fmt.Errorf("error decoding base64: %v", err)
 	}
 	var k Kind
 	err = json.Unmarshal(f, &k)
 	if err != nil {
-		return Entry{}, fmt.Errorf("error unmarshalling kind: %v", err)
+		return fmt.Errorf("error unmarshalling kind: %v", err)
 	}
 	value.Kind = Kind{
 		APIVersion: k.APIVersion,
@@ -96,26 +141,23 @@ func (t *tlog) Entry(index int64) (Entry, error) {
 	case "rekord":
 		rekord, err := handleRekord(f)
 		if err != nil {
-			return Entry{}, fmt.Errorf("error handling rekord: %v", err)
+			return fmt.Errorf("error handling rekord: %v", err)
 		}
 		value.Rekord = &rekord
 	case "hashedrekord":
 		rekord, err := handleHashedRekord(f)
 		if err != nil {
-			return Entry{}, fmt.Errorf("error handling hashedrekord: %v", err)
+			return fmt.Errorf("error handling hashedrekord: %v", err)
 		}
 		value.HashedRekord = &rekord
 	case "intoto":
 		intoto, err := handleIntoto(f)
 		if err != nil {
-			return Entry{}, fmt.Errorf("error handling intoto: %w", err)
+			return fmt.Errorf("error handling intoto: %w", err)
 		}
 		value.Intoto = &intoto
-	default:
-		return value, nil
 	}
-	value.Date = time.Now()
-	return value, nil
+	return nil
 }
 
 // handleIntoto handles the intoto entry.
@@ -141,6 +183,10 @@ func handleIntoto(s []byte) (InToTo, error) {
 	if err == nil {
 		e.Signature.X509 = identity
 	}
+	info, err := getPublicKeyInfo(p)
+	if err == nil {
+		e.Signature.PublicKeyInfo = info
+	}
 
 	return e, nil
 }
@@ -168,6 +214,10 @@ func handleHashedRekord(s []byte) (Hashedrekord, error) {
 	if err == nil {
 		e.Signature.X509 = identity
 	}
+	info, err := getPublicKeyInfo(p)
+	if err == nil {
+		e.Signature.PublicKeyInfo = info
+	}
 
 	return e, nil
 }
@@ -192,43 +242,15 @@ func handleRekord(f []byte) (Rekord, error) {
 	if err != nil {
 		return Rekord{}, fmt.Errorf("error decoding public key: %v", err)
 	}
-	var pkeyText string
-	p := string(publicKey)
-	format := i.Spec.Signature.Format
-	e.Signature.PublicKey = p
-	if format == "pgp" {
-		pkeyText, err = getPGPIdentity(string(publicKey))
-		if err != nil {
-			return Rekord{}, fmt.Errorf("error getting public key identities: %v", err)
-		}
-		e.Signature.PGP = pkeyText
-	} else {
-		identity, err := getx509Identity(string(publicKey))
-		if err == nil {
-			e.Signature.X509 = identity
-		}
-	}
-	return e, nil
-}
-
-// getPGPIdentity returns the identities of the given public key.
-func getPGPIdentity(p string) (string, error) {
-	b := strings.Builder{}
-	result := ""
-	r := bytes.NewReader([]byte(p))
-	keys, err := openpgp.ReadArmoredKeyRing(r)
-	if err != nil {
-		return "", fmt.Errorf("Unable to read armored key ring: %s \n  %v\n", p, err)
+	e.Signature.PublicKey = string(publicKey)
+	parse, ok := signatureParsers[i.Spec.Signature.Format]
+	if !ok {
+		parse = parseX509Signature
 	}
-	for _, key := range keys {
-		for name := range key.Identities {
-			b.WriteString(name)
-			b.WriteString("\n")
-		}
+	if err := parse(publicKey, &e.Signature); err != nil {
+		return Rekord{}, fmt.Errorf("error getting public key identities: %v", err)
 	}
-	result = b.String()
-	result = strings.ReplaceAll(result, "\n", "")
-	return result, nil
+	return e, nil
 }
 
 // getEntry returns the entry from the given tlogEntry.
@@ -254,6 +276,11 @@ func getx509Identity(publicKey string) (*X509, error) {
 	if err != nil {
 		return nil, err
 	}
+	return x509Identity(cert), nil
+}
+
+// x509Identity returns the identities of the given certificate.
+func x509Identity(cert *x509.Certificate) *X509 {
 	serialNumber := cert.SerialNumber.String()
 	signatureAlgorithm := cert.SignatureAlgorithm.String()
 	var extension []X509Extension //nolint:prealloc
@@ -292,5 +319,5 @@ func getx509Identity(publicKey string) (*X509, error) {
 		certificate.IssuerOrganization = cert.Issuer.Organization[0]
 	}
 
-	return &certificate, nil
+	return &certificate
 }
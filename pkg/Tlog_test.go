@@ -1,7 +1,21 @@
 package pkg
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestTLogSize(t1 *testing.T) {
@@ -42,3 +56,209 @@ func TestTLogSize(t1 *testing.T) {
 		})
 	}
 }
+
+func TestDecodeEntryParseError(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "invalid base64", body: "not base64!"},
+		{name: "invalid json", body: "bm90IGpzb24="},
+		{name: "invalid rekord", body: "eyJraW5kIjoicmVrb3JkIiwic3BlYyI6MX0="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decodeEntry(tlogEntry{Body: tt.body, LogIndex: 42, LogID: "id", IntegratedTime: 1})
+			if got.LogIndex != 42 || got.LogID != "id" || got.IntegratedTime != 1 {
+				t.Errorf("decodeEntry() envelope not kept, got %+v", got)
+			}
+			if got.ParseError == "" {
+				t.Errorf("decodeEntry() expected a parse error")
+			}
+			if got.Body != tt.body {
+				t.Errorf("decodeEntry() body = %q, want %q", got.Body, tt.body)
+			}
+		})
+	}
+}
+
+// testCertificate returns a PEM encoded self signed certificate with a Fulcio issuer extension.
+func testCertificate(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "sigstore-intermediate", Organization: []string{"sigstore.dev"}},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}, Value: []byte("https://accounts.google.com")},
+		},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}))
+}
+
+// testRekorResponse returns a rekor API response holding a single entry with the given body.
+func testRekorResponse(t *testing.T, index int, body interface{}) []byte {
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(map[string]tlogEntry{
+		"uuid": {Body: base64.StdEncoding.EncodeToString(data), LogIndex: index, LogID: "id", IntegratedTime: 1672628645},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestDecodeEntry(t *testing.T) {
+	cert := base64.StdEncoding.EncodeToString([]byte(testCertificate(t)))
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bareKey := base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	hash := map[string]string{"algorithm": "sha256", "value": "abc"}
+
+	tests := []struct {
+		name  string
+		body  interface{}
+		check func(t *testing.T, e Entry)
+	}{
+		{
+			name: "hashedrekord",
+			body: map[string]interface{}{"apiVersion": "0.0.1", "kind": "hashedrekord", "spec": map[string]interface{}{
+				"data":      map[string]interface{}{"hash": hash},
+				"signature": map[string]interface{}{"publicKey": map[string]string{"content": cert}},
+			}},
+			check: func(t *testing.T, e Entry) {
+				if e.HashedRekord == nil || e.HashedRekord.Data.Hash.Value != "abc" {
+					t.Fatalf("hashedrekord = %+v", e.HashedRekord)
+				}
+				x := e.HashedRekord.Signature.X509
+				if x == nil || x.IssuerOrganization != "sigstore.dev" || x.SerialNumber != "42" {
+					t.Fatalf("x509 = %+v", x)
+				}
+				if len(x.Extensions) != 1 || x.Extensions[0].ID != "1.3.6.1.4.1.57264.1.1" ||
+					x.Extensions[0].Value != "https://accounts.google.com" {
+					t.Errorf("extensions = %+v", x.Extensions)
+				}
+				if e.HashedRekord.Signature.PublicKeyInfo == nil {
+					t.Error("public key info is missing")
+				}
+			},
+		},
+		{
+			name: "intoto",
+			body: map[string]interface{}{"apiVersion": "0.0.1", "kind": "intoto", "spec": map[string]interface{}{
+				"content":   map[string]interface{}{"hash": hash},
+				"publicKey": bareKey,
+			}},
+			check: func(t *testing.T, e Entry) {
+				if e.Intoto == nil || e.Intoto.Data.Hash.Algorithm != "sha256" {
+					t.Fatalf("intoto = %+v", e.Intoto)
+				}
+				// a bare key has no certificate, only its key info
+				if e.Intoto.Signature.X509 != nil || e.Intoto.Signature.PublicKeyInfo == nil ||
+					e.Intoto.Signature.PublicKeyInfo.Curve != "P-256" {
+					t.Errorf("signature = %+v", e.Intoto.Signature)
+				}
+			},
+		},
+		{
+			name: "rekord",
+			body: map[string]interface{}{"apiVersion": "0.0.1", "kind": "rekord", "spec": map[string]interface{}{
+				"data": map[string]interface{}{"hash": hash},
+				"signature": map[string]interface{}{"format": "pgp",
+					"publicKey": map[string]string{"content": base64.StdEncoding.EncodeToString([]byte(testPGPKey))}},
+			}},
+			check: func(t *testing.T, e Entry) {
+				if e.Rekord == nil || e.Rekord.Signature.Format != "pgp" {
+					t.Fatalf("rekord = %+v", e.Rekord)
+				}
+				if len(e.Rekord.Signature.PGPKeys) != 1 || len(e.Rekord.Signature.Keys) != 1 {
+					t.Errorf("signature = %+v", e.Rekord.Signature)
+				}
+			},
+		},
+		{
+			name: "invalid rekord",
+			body: map[string]interface{}{"apiVersion": "0.0.1", "kind": "rekord", "spec": map[string]interface{}{
+				"signature": map[string]interface{}{"format": "pgp",
+					"publicKey": map[string]string{"content": base64.StdEncoding.EncodeToString([]byte("not a key"))}},
+			}},
+			check: func(t *testing.T, e Entry) {
+				if e.ParseError == "" || e.Body == "" || e.Rekord != nil {
+					t.Errorf("parse error = %q, body = %q, rekord = %+v", e.ParseError, e.Body, e.Rekord)
+				}
+				if e.Kind.Kind != "rekord" {
+					t.Errorf("kind = %q, want the kind of the body", e.Kind.Kind)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeEntry(testRekorResponse(t, 7, tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.LogIndex != 7 || got.LogID != "id" || got.IntegratedTime != 1672628645 {
+				t.Errorf("envelope = %d %s %d", got.LogIndex, got.LogID, got.IntegratedTime)
+			}
+			if got.DecoderVersion != DecoderVersions[got.Kind.Kind] {
+				t.Errorf("decoder version = %d", got.DecoderVersion)
+			}
+			tt.check(t, got)
+		})
+	}
+}
+
+func TestDecodeEntryResponse(t *testing.T) {
+	for _, raw := range []string{"not json", "{}"} {
+		if _, err := DecodeEntry([]byte(raw)); err == nil {
+			t.Errorf("DecodeEntry(%q) error = nil, want an error", raw)
+		}
+	}
+}
+
+func TestRawEntry(t *testing.T) {
+	response := testRekorResponse(t, 7, map[string]string{"kind": "unknown"})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/log/entries" || r.URL.Query().Get("logIndex") != "7" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, string(response))
+	}))
+	defer server.Close()
+	tl := NewTLog(server.URL)
+
+	raw, err := tl.RawEntry(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the response is archived unmodified
+	if string(raw) != string(response) {
+		t.Errorf("RawEntry() = %s, want %s", raw, response)
+	}
+	entry, err := tl.Entry(7)
+	if err != nil || entry.LogIndex != 7 {
+		t.Errorf("Entry() = %+v, %v", entry, err)
+	}
+	if _, err := tl.RawEntry(8); err == nil {
+		t.Error("RawEntry() error = nil for a missing entry")
+	}
+}
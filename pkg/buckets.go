@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"time"
 
 	"cloud.google.com/go/storage"
@@ -12,37 +13,74 @@ import (
 
 type Bucket interface {
 	UpdateBucket(item Entry) error
+	// UpdateBucketRaw writes the unmodified rekor API response next to the entry.
+	UpdateBucketRaw(index int64, raw []byte) error
+	// RawEntry reads the archived rekor API response for the given log index.
+	RawEntry(index int64) ([]byte, error)
 }
 type bucket struct {
-	Name string
+	Name   string
+	client *storage.Client
 }
 
+// NewBucket returns a Bucket backed by the GCS bucket with the given name. The storage client is created once
+// and shared by all the reads and writes, Close releases it.
 func NewBucket(name string) (Bucket, error) {
 	if name == "" {
 		return nil, fmt.Errorf("bucket name is required")
 	}
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("storage.NewClient: %w", err)
+	}
 	return &bucket{
-		Name: name,
+		Name:   name,
+		client: client,
 	}, nil
 }
 
+// Close closes the storage client.
+func (b bucket) Close() error {
+	return b.client.Close()
+}
+
 func (b bucket) UpdateBucket(item Entry) error {
+	json, err := Marshal(item)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	return b.write(fmt.Sprintf("%d/entry.json", item.LogIndex), json)
+}
+
+// UpdateBucketRaw writes the raw rekor response to <index>/raw.json so the archive can be re-decoded later.
+func (b bucket) UpdateBucketRaw(index int64, raw []byte) error {
+	return b.write(fmt.Sprintf("%d/raw.json", index), raw)
+}
+
+// RawEntry reads <index>/raw.json from the bucket.
+func (b bucket) RawEntry(index int64) ([]byte, error) {
 	ctx := context.Background()
 
 	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
-	client, err := storage.NewClient(ctx)
+	path := fmt.Sprintf("%d/raw.json", index)
+	rc, err := b.client.Bucket(b.Name).Object(path).NewReader(ctx)
 	if err != nil {
-		return fmt.Errorf("storage.NewClient: %w", err)
+		return nil, fmt.Errorf("Object(%q).NewReader: %w", path, err)
 	}
-	path := fmt.Sprintf("%d/entry.json", item.LogIndex)
-	wc := client.Bucket(b.Name).Object(path).NewWriter(ctx)
+	//nolint
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func (b bucket) write(path string, data []byte) error {
+	ctx := context.Background()
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+	wc := b.client.Bucket(b.Name).Object(path).NewWriter(ctx)
 	wc.ContentType = "application/json"
-	json, err := Marshal(item)
-	if err != nil {
-		return fmt.Errorf("json.Marshal: %w", err)
-	}
-	if _, err := wc.Write(json); err != nil {
+	if _, err := wc.Write(data); err != nil {
 		return fmt.Errorf("Object(%q).Writer: %w", path, err)
 	}
 	return wc.Close()
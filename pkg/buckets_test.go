@@ -0,0 +1,98 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/storage"
+)
+
+// gcsServer is a minimal GCS emulator, it stores the uploaded objects and serves them back.
+type gcsServer struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *gcsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if strings.HasPrefix(r.URL.Path, "/upload/storage/v1/b/") {
+		name, data, err := readMultipartObject(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		bucket := strings.Split(strings.TrimPrefix(r.URL.Path, "/upload/storage/v1/b/"), "/")[0]
+		s.objects[bucket+"/"+name] = data
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"bucket":"`+bucket+`","name":"`+name+`"}`)
+		return
+	}
+	data, ok := s.objects[strings.TrimPrefix(r.URL.Path, "/")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	_, _ = w.Write(data)
+}
+
+// readMultipartObject returns the name and the content of a multipart upload.
+func readMultipartObject(r *http.Request) (string, []byte, error) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return "", nil, err
+	}
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	// the first part is the JSON metadata of the object, the second its content
+	part, err := reader.NextPart()
+	if err != nil {
+		return "", nil, err
+	}
+	var metadata struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(part).Decode(&metadata); err != nil {
+		return "", nil, err
+	}
+	if part, err = reader.NextPart(); err != nil {
+		return "", nil, err
+	}
+	data, err := io.ReadAll(part)
+	return metadata.Name, data, err
+}
+
+func TestBucketRawEntry(t *testing.T) {
+	server := httptest.NewServer(&gcsServer{objects: map[string][]byte{}})
+	defer server.Close()
+	t.Setenv("STORAGE_EMULATOR_HOST", server.URL)
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bucket{Name: "rekor", client: client}
+	//nolint
+	defer b.Close()
+
+	raw := testRekorResponse(t, 7, map[string]string{"kind": "unknown"})
+	if err := b.UpdateBucketRaw(7, raw); err != nil {
+		t.Fatal(err)
+	}
+	got, err := b.RawEntry(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(raw) {
+		t.Errorf("RawEntry() = %s, want %s", got, raw)
+	}
+	if _, err := b.RawEntry(8); err == nil {
+		t.Error("RawEntry() error = nil for a missing entry")
+	}
+}
@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// DirectoryOptions configures how entries are written to a local directory.
+type DirectoryOptions struct {
+	// FanOut nests the <index> directories under <index/1000000>/<index/1000%1000> so that
+	// no single directory holds millions of children.
+	FanOut bool
+	// Fsync syncs every file before it is renamed into place.
+	Fsync bool
+}
+
+type directory struct {
+	Root string
+	DirectoryOptions
+}
+
+// NewDirectory returns a Bucket backed by a local directory using the same <index>/<object> layout as the GCS bucket.
+func NewDirectory(root string, opts DirectoryOptions) (Bucket, error) {
+	if root == "" {
+		return nil, fmt.Errorf("directory is required")
+	}
+	return &directory{
+		Root:             root,
+		DirectoryOptions: opts,
+	}, nil
+}
+
+func (d directory) UpdateBucket(item Entry) error {
+	json, err := Marshal(item)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	return d.write(int64(item.LogIndex), "entry.json", json)
+}
+
+func (d directory) UpdateBucketRaw(index int64, raw []byte) error {
+	return d.write(index, "raw.json", raw)
+}
+
+// RawEntry reads <root>/<index>/raw.json.
+func (d directory) RawEntry(index int64) ([]byte, error) {
+	return os.ReadFile(filepath.Join(d.dir(index), "raw.json"))
+}
+
+// dir returns the directory holding the objects of the given index.
+func (d directory) dir(index int64) string {
+	name := strconv.FormatInt(index, 10)
+	if !d.FanOut {
+		return filepath.Join(d.Root, name)
+	}
+	return filepath.Join(d.Root, strconv.FormatInt(index/1000000, 10), strconv.FormatInt(index/1000%1000, 10), name)
+}
+
+// write writes the object atomically by renaming a temporary file into place.
+func (d directory) write(index int64, name string, data []byte) error {
+	dir := d.dir(index)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+name+"-*")
+	if err != nil {
+		return fmt.Errorf("os.CreateTemp: %w", err)
+	}
+	//nolint
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		//nolint
+		tmp.Close()
+		return fmt.Errorf("File(%q).Write: %w", tmp.Name(), err)
+	}
+	if d.Fsync {
+		if err := tmp.Sync(); err != nil {
+			//nolint
+			tmp.Close()
+			return fmt.Errorf("File(%q).Sync: %w", tmp.Name(), err)
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("File(%q).Close: %w", tmp.Name(), err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil { //nolint:gosec
+		return fmt.Errorf("os.Chmod: %w", err)
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
+
+// NewFilesystemSink returns a Sink that writes <root>/<index>/entry.json and raw.json like the GCS bucket sink.
+func NewFilesystemSink(root string, opts DirectoryOptions) (Sink, error) {
+	d, err := NewDirectory(root, opts)
+	if err != nil {
+		return nil, err
+	}
+	return NewBucketSink(d)
+}
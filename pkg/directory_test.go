@@ -0,0 +1,85 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirectoryLayout(t *testing.T) {
+	tests := []struct {
+		name string
+		opts DirectoryOptions
+		want string
+	}{
+		{name: "flat", opts: DirectoryOptions{}, want: "1234567/entry.json"},
+		{name: "fanout", opts: DirectoryOptions{FanOut: true, Fsync: true}, want: "1/234/1234567/entry.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			sink, err := NewFilesystemSink(root, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.Write(Entry{LogIndex: 1234567}); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := sink.(RawSink).WriteRaw(1234567, []byte(`{"raw":true}`)); err != nil {
+				t.Fatalf("WriteRaw() error = %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(root, tt.want)); err != nil {
+				t.Errorf("entry not written to %s: %v", tt.want, err)
+			}
+			d, _ := NewDirectory(root, tt.opts)
+			raw, err := d.RawEntry(1234567)
+			if err != nil || string(raw) != `{"raw":true}` {
+				t.Errorf("RawEntry() = %s, %v", raw, err)
+			}
+			files, _ := os.ReadDir(filepath.Dir(filepath.Join(root, tt.want)))
+			if len(files) != 2 {
+				t.Errorf("expected no temporary files left, got %d files", len(files))
+			}
+		})
+	}
+}
+
+func TestDirectoryRawEntry(t *testing.T) {
+	root := t.TempDir()
+	// an archive written with fan-out is only found when it is read with fan-out
+	written, err := NewDirectory(root, DirectoryOptions{FanOut: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := written.UpdateBucketRaw(2345678, []byte(`{"raw":true}`)); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		opts    DirectoryOptions
+		index   int64
+		wantErr bool
+	}{
+		{name: "fanout", opts: DirectoryOptions{FanOut: true}, index: 2345678},
+		{name: "flat", opts: DirectoryOptions{}, index: 2345678, wantErr: true},
+		{name: "missing", opts: DirectoryOptions{FanOut: true}, index: 2345679, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDirectory(root, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, err := d.RawEntry(tt.index)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RawEntry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !os.IsNotExist(err) {
+				t.Errorf("RawEntry() error = %v, want a not exist error", err)
+			}
+			if !tt.wantErr && string(raw) != `{"raw":true}` {
+				t.Errorf("RawEntry() = %s", raw)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// signatureParser populates the format specific fields of the signature from the decoded public key.
+type signatureParser func(publicKey []byte, s *Signature) error
+
+// signatureParsers holds the parser for each supported rekord signature format.
+// Formats that are not listed are parsed as x509.
+var signatureParsers = map[string]signatureParser{
+	"pgp":      parsePGPSignature,
+	"ssh":      parseSSHSignature,
+	"minisign": parseMinisignSignature,
+	"pkcs7":    parsePKCS7Signature,
+	"x509":     parseX509Signature,
+}
+
+// parsePGPSignature parses an armored or binary PGP key ring.
+func parsePGPSignature(publicKey []byte, s *Signature) error {
+	keys, err := getPGPKeys(publicKey)
+	if err != nil {
+		return err
+	}
+	s.PGP = pgpIdentities(keys)
+	s.PGPKeys = keys
+	for _, k := range keys {
+		key := SignatureKey{
+			Format:      "pgp",
+			Algorithm:   k.Algorithm,
+			Fingerprint: k.Fingerprint,
+			KeyID:       k.KeyID,
+		}
+		for _, uid := range k.UIDs {
+			if uid.Email != "" {
+				key.Identities = append(key.Identities, uid.Email)
+			} else {
+				key.Identities = append(key.Identities, uid.Name)
+			}
+		}
+		s.Keys = append(s.Keys, key)
+	}
+	return nil
+}
+
+// parseSSHSignature parses a public key in the authorized_keys format.
+func parseSSHSignature(publicKey []byte, s *Signature) error {
+	pub, comment, _, _, err := ssh.ParseAuthorizedKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("unable to parse ssh public key: %w", err)
+	}
+	key := SignatureKey{
+		Format:      "ssh",
+		Algorithm:   pub.Type(),
+		Fingerprint: ssh.FingerprintSHA256(pub),
+	}
+	if comment != "" {
+		key.Identities = append(key.Identities, comment)
+	}
+	if cert, ok := pub.(*ssh.Certificate); ok {
+		key.KeyID = cert.KeyId
+		key.Identities = append(key.Identities, cert.ValidPrincipals...)
+	}
+	s.Keys = append(s.Keys, key)
+	return nil
+}
+
+// parseMinisignSignature parses a minisign public key, with or without the untrusted comment line.
+func parseMinisignSignature(publicKey []byte, s *Signature) error {
+	var comment, encoded string
+	for _, line := range strings.Split(strings.TrimSpace(string(publicKey)), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "untrusted comment:") {
+			comment = strings.TrimSpace(strings.TrimPrefix(line, "untrusted comment:"))
+			continue
+		}
+		if line != "" {
+			encoded = line
+		}
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("unable to decode minisign public key: %w", err)
+	}
+	// signature algorithm (2 bytes) || key id (8 bytes) || ed25519 public key (32 bytes)
+	if len(raw) != 42 || !bytes.Equal(raw[:2], []byte("Ed")) {
+		return fmt.Errorf("invalid minisign public key")
+	}
+	sum := sha256.Sum256(raw[10:])
+	key := SignatureKey{
+		Format:      "minisign",
+		Algorithm:   "Ed25519",
+		Fingerprint: hex.EncodeToString(sum[:]),
+		KeyID:       fmt.Sprintf("%016X", binary.LittleEndian.Uint64(raw[2:10])),
+	}
+	if comment != "" {
+		key.Identities = append(key.Identities, comment)
+	}
+	s.Keys = append(s.Keys, key)
+	return nil
+}
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue
+}
+
+// parsePKCS7Signature parses a PEM or DER encoded PKCS#7 SignedData structure and reports its certificates.
+// The first certificate is recorded as the X509 identity of the signature.
+func parsePKCS7Signature(publicKey []byte, s *Signature) error {
+	der := publicKey
+	if block, _ := pem.Decode(publicKey); block != nil {
+		der = block.Bytes
+	}
+	var info pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return fmt.Errorf("unable to parse pkcs7 content info: %w", err)
+	}
+	// content is a [0] EXPLICIT SignedData.
+	var signed pkcs7SignedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &signed); err != nil {
+		return fmt.Errorf("unable to parse pkcs7 signed data: %w", err)
+	}
+	// certificates is an optional [0] IMPLICIT SET OF Certificate.
+	if signed.Certificates.Class != asn1.ClassContextSpecific || signed.Certificates.Tag != 0 {
+		return fmt.Errorf("pkcs7 signed data does not contain certificates")
+	}
+	certs, err := x509.ParseCertificates(signed.Certificates.Bytes)
+	if err != nil {
+		return fmt.Errorf("unable to parse pkcs7 certificates: %w", err)
+	}
+	for i, cert := range certs {
+		if i == 0 {
+			s.X509 = x509Identity(cert)
+		}
+		s.Keys = append(s.Keys, certificateKey("pkcs7", cert))
+	}
+	if len(certs) > 0 {
+		s.PublicKeyInfo, _ = getPublicKeyInfo(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certs[0].Raw})))
+	}
+	return nil
+}
+
+// parseX509Signature parses a PEM encoded certificate or PKIX public key.
+// Keys that cannot be parsed are not an error, only the PEM text is kept.
+func parseX509Signature(publicKey []byte, s *Signature) error {
+	info, err := getPublicKeyInfo(string(publicKey))
+	if err != nil {
+		return nil
+	}
+	s.PublicKeyInfo = info
+	block, _ := pem.Decode(publicKey)
+	if block.Type == "CERTIFICATE" {
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			s.X509 = x509Identity(cert)
+			s.Keys = append(s.Keys, certificateKey("x509", cert))
+			return nil
+		}
+	}
+	s.Keys = append(s.Keys, SignatureKey{
+		Format:      "x509",
+		Algorithm:   info.Algorithm,
+		Fingerprint: info.FingerprintSHA256,
+	})
+	return nil
+}
+
+// certificateKey returns the SignatureKey of a certificate, using its subject alternative names as identities.
+func certificateKey(format string, cert *x509.Certificate) SignatureKey {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	key := SignatureKey{
+		Format:      format,
+		Algorithm:   cert.PublicKeyAlgorithm.String(),
+		Fingerprint: hex.EncodeToString(sum[:]),
+		KeyID:       hex.EncodeToString(cert.SubjectKeyId),
+	}
+	key.Identities = append(key.Identities, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		key.Identities = append(key.Identities, u.String())
+	}
+	key.Identities = append(key.Identities, cert.DNSNames...)
+	if len(key.Identities) == 0 && cert.Subject.CommonName != "" {
+		key.Identities = append(key.Identities, cert.Subject.CommonName)
+	}
+	return key
+}
@@ -0,0 +1,139 @@
+package pkg
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	//nolint
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSignatureParsers(t *testing.T) {
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(edPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshKey := append(ssh.MarshalAuthorizedKey(sshPub)[:len(ssh.MarshalAuthorizedKey(sshPub))-1], []byte(" user@example.com\n")...)
+
+	minisignRaw := append([]byte("Ed"), []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}...)
+	minisignRaw = append(minisignRaw, edPub...)
+	minisignKey := "untrusted comment: minisign public key 0807060504030201\n" +
+		base64.StdEncoding.EncodeToString(minisignRaw) + "\n"
+
+	entity, err := openpgp.NewEntity("Jane Doe", "release", "jane@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var binaryPGP bytesBuffer
+	if err := entity.Serialize(&binaryPGP); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		format    string
+		key       []byte
+		keyID     string
+		identity  string
+		algorithm string
+	}{
+		{name: "ssh", format: "ssh", key: sshKey, identity: "user@example.com", algorithm: ssh.KeyAlgoED25519},
+		{name: "minisign", format: "minisign", key: []byte(minisignKey), keyID: "0807060504030201",
+			identity: "minisign public key 0807060504030201", algorithm: "Ed25519"},
+		{name: "binary pgp", format: "pgp", key: binaryPGP, keyID: entity.PrimaryKey.KeyIdString(),
+			identity: "jane@example.com", algorithm: "RSA"},
+		{name: "pkcs7", format: "pkcs7", key: testPKCS7(t), identity: "signer@example.com", algorithm: "ECDSA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s Signature
+			if err := signatureParsers[tt.format](tt.key, &s); err != nil {
+				t.Fatalf("parse error = %v", err)
+			}
+			if len(s.Keys) != 1 {
+				t.Fatalf("got %d keys, want 1", len(s.Keys))
+			}
+			k := s.Keys[0]
+			if k.Format != tt.format || k.Algorithm != tt.algorithm || k.Fingerprint == "" {
+				t.Errorf("got key %+v", k)
+			}
+			if tt.keyID != "" && k.KeyID != tt.keyID {
+				t.Errorf("got key id %q, want %q", k.KeyID, tt.keyID)
+			}
+			if len(k.Identities) == 0 || k.Identities[0] != tt.identity {
+				t.Errorf("got identities %v, want %q", k.Identities, tt.identity)
+			}
+		})
+	}
+}
+
+type bytesBuffer []byte
+
+func (b *bytesBuffer) Write(p []byte) (int, error) {
+	*b = append(*b, p...)
+	return len(p), nil
+}
+
+// testPKCS7 returns a DER encoded PKCS#7 SignedData holding a single self signed certificate.
+func testPKCS7(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{CommonName: "signer"},
+		NotBefore:      time.Now(),
+		NotAfter:       time.Now().Add(time.Hour),
+		EmailAddresses: []string{"signer@example.com"},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := asn1.Marshal(struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      asn1.RawValue
+		Certificates     asn1.RawValue
+		SignerInfos      asn1.RawValue
+	}{
+		Version:          1,
+		DigestAlgorithms: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true},
+		ContentInfo:      asn1.RawValue{FullBytes: mustMarshal(t, struct{ ContentType asn1.ObjectIdentifier }{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}})},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: cert},
+		SignerInfos:      asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mustMarshal(t, struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{
+		ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2},
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signed},
+	})
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	b, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
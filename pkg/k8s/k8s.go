@@ -31,18 +31,25 @@ type K8s interface {
 	GetPendingRanges(dataset, table string, chunkSize int64) ([]Range, error)
 	// CreateJob creates a job in the given namespace within the k8s cluster.
 	CreateJob(r Range) error
+	// CreateReprocessJob creates a job that re-decodes the entries of the given kind within the range.
+	CreateReprocessJob(r Range, kind string) error
+	// CreateLoadJob creates a job like CreateJob that writes to BigQuery with a load job instead of streaming inserts.
+	CreateLoadJob(r Range) error
 }
 type k8s struct {
 	phren     pkg.Phren
 	tlog      pkg.TLog
 	hostname  string
+	project   string
 	dataset   string
 	table     string
+	bucket    string
 	namespace string
 }
 
-// New returns a new instance of K8s
-func New(phren pkg.Phren, tlog pkg.TLog, hostname, dataset, table, namespace string) (K8s, error) {
+// New returns a new instance of K8s, the jobs use the BigQuery project when it is set and detect it otherwise.
+// The jobs write to, and read from, the GCS bucket when it is set and to the default bucket of rekor-phren otherwise.
+func New(phren pkg.Phren, tlog pkg.TLog, hostname, project, dataset, table, bucket, namespace string) (K8s, error) {
 	// validate the inputs
 	if phren == nil {
 		return nil, fmt.Errorf("phren cannot be nil")
@@ -59,7 +66,8 @@ func New(phren pkg.Phren, tlog pkg.TLog, hostname, dataset, table, namespace str
 	if table == "" {
 		return nil, fmt.Errorf("table cannot be empty")
 	}
-	return &k8s{phren: phren, tlog: tlog, hostname: hostname, dataset: dataset, table: table, namespace: namespace}, nil
+	return &k8s{phren: phren, tlog: tlog, hostname: hostname, project: project, dataset: dataset, table: table,
+		bucket: bucket, namespace: namespace}, nil
 }
 
 // GetPendingRanges returns the ranges for start and end for the given chunkSize which can be parallelized.
@@ -106,6 +114,46 @@ func (k k8s) GetPendingRanges(dataset, table string, chunkSize int64) ([]Range,
 
 // CreateJob creates a job in the given namespace within the k8s cluster.
 func (k k8s) CreateJob(r Range) error {
+	return k.createJob(fmt.Sprintf("phren-%d-%d", r.From, r.To), []string{"rekor-phren", "--bigquery-dataset", k.dataset,
+		"--bigquery-table-name", k.table, "--rekor-url", k.hostname, "--start-index", fmt.Sprintf("%d", r.From),
+		"--end-index", fmt.Sprintf("%d", r.To), "update"})
+}
+
+// CreateLoadJob creates a job like CreateJob that writes to BigQuery with a load job instead of streaming inserts.
+func (k k8s) CreateLoadJob(r Range) error {
+	return k.createJob(fmt.Sprintf("phren-load-%d-%d", r.From, r.To), []string{"rekor-phren", "--bigquery-dataset", k.dataset,
+		"--bigquery-table-name", k.table, "--rekor-url", k.hostname, "--start-index", fmt.Sprintf("%d", r.From),
+		"--end-index", fmt.Sprintf("%d", r.To), "update", "--bigquery-load"})
+}
+
+// CreateReprocessJob creates a job that re-decodes the entries of the given kind within the range.
+func (k k8s) CreateReprocessJob(r Range, kind string) error {
+	return k.createJob(fmt.Sprintf("phren-reprocess-%s-%d-%d", kind, r.From, r.To), []string{"rekor-phren",
+		"--bigquery-dataset", k.dataset, "--bigquery-table-name", k.table, "--rekor-url", k.hostname,
+		"--start-index", fmt.Sprintf("%d", r.From), "--end-index", fmt.Sprintf("%d", r.To), "reprocess", "--kind", kind})
+}
+
+// Ranges groups the sorted indexes into ranges that span at most chunkSize indexes. The indexes in between are
+// reprocessed too, which leaves the rows of other kinds alone as the reprocess jobs filter by kind.
+func Ranges(indexes []int64, chunkSize int64) []Range {
+	var result []Range
+	for _, i := range indexes {
+		if n := len(result); n > 0 && i-result[n-1].From < chunkSize {
+			result[n-1].To = i
+			continue
+		}
+		result = append(result, Range{From: i, To: i})
+	}
+	return result
+}
+
+func (k k8s) createJob(name string, command []string) error {
+	if k.project != "" {
+		command = append([]string{command[0], "--bigquery-project", k.project}, command[1:]...)
+	}
+	if k.bucket != "" {
+		command = append([]string{command[0], "--gcs-bucket-name", k.bucket}, command[1:]...)
+	}
 	config, err := buildConfig("")
 	if err != nil {
 		return fmt.Errorf("failed to build config: %w", err)
@@ -118,7 +166,7 @@ func (k k8s) CreateJob(r Range) error {
 	jobsClient := clientset.BatchV1().Jobs("default")
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: fmt.Sprintf("phren-%d-%d", r.From, r.To),
+			Name: name,
 		},
 		Spec: batchv1.JobSpec{
 			TTLSecondsAfterFinished: &deleteJobTime,
@@ -126,10 +174,9 @@ func (k k8s) CreateJob(r Range) error {
 				Spec: corev1.PodSpec{
 					Containers: []corev1.Container{
 						{
-							Name:  "phren",
-							Image: "gcr.io/openssf/rekor-phren-c5fc4a6e85fec69cce84b35fd28b14cc@sha256:4a52ce50e4e240b84d69e04f87d3df684f03a21640cb9229bd4fe8f63b1afc43",
-							Command: []string{"rekor-phren", "--bigquery-dataset", k.dataset, "--bigquery-table-name",
-								k.table, "--rekor-url", k.hostname, "--start-index", fmt.Sprintf("%d", r.From), "--end-index", fmt.Sprintf("%d", r.To), "update"},
+							Name:    "phren",
+							Image:   "gcr.io/openssf/rekor-phren-c5fc4a6e85fec69cce84b35fd28b14cc@sha256:4a52ce50e4e240b84d69e04f87d3df684f03a21640cb9229bd4fe8f63b1afc43",
+							Command: command,
 						},
 					},
 					RestartPolicy:      corev1.RestartPolicyNever,
@@ -0,0 +1,37 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRanges(t *testing.T) {
+	tests := []struct {
+		name      string
+		indexes   []int64
+		chunkSize int64
+		want      []Range
+	}{
+		{name: "empty", chunkSize: 10},
+		{name: "consecutive", indexes: []int64{1, 2, 3}, chunkSize: 10, want: []Range{{From: 1, To: 3}}},
+		{
+			name:      "scattered",
+			indexes:   []int64{1, 4, 9, 10, 15, 25},
+			chunkSize: 10,
+			want:      []Range{{From: 1, To: 10}, {From: 15, To: 15}, {From: 25, To: 25}},
+		},
+		{
+			name:      "chunk size",
+			indexes:   []int64{0, 1, 2, 3, 4},
+			chunkSize: 2,
+			want:      []Range{{From: 0, To: 1}, {From: 2, To: 3}, {From: 4, To: 4}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Ranges(tt.indexes, tt.chunkSize); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Ranges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
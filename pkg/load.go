@@ -0,0 +1,197 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/storage"
+)
+
+// LoadOptions configures the BigQuery load job sink.
+type LoadOptions struct {
+	Dataset string
+	Table   string
+	// Bucket is the GCS bucket the NDJSON files are staged in.
+	Bucket string
+	// Prefix is the staging prefix in the bucket, defaults to staging.
+	Prefix string
+	// MaxEntries is the number of entries in a staged file, defaults to 50000.
+	MaxEntries int
+}
+
+type loadSink struct {
+	opts    LoadOptions
+	bq      *bigquery.Client
+	gcs     *storage.Client
+	mu      sync.Mutex
+	batch   []Entry
+	run     string
+	staged  []string
+	counter int
+}
+
+// NewBigQueryLoadSink returns a Sink that stages the entries as NDJSON files in the bucket and loads them into the
+// table with a load job on Flush, appending the rows and allowing new and relaxed columns. The staged files are
+// deleted once they were loaded. Load jobs are free, unlike streaming inserts, which makes this the cheaper way
+// to backfill large ranges.
+func NewBigQueryLoadSink(bq *BigQuery, opts LoadOptions) (Sink, error) {
+	if bq == nil {
+		return nil, fmt.Errorf("bigquery repository is required")
+	}
+	if opts.Dataset == "" {
+		return nil, fmt.Errorf("dataset is required")
+	}
+	if opts.Table == "" {
+		return nil, fmt.Errorf("table is required")
+	}
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("staging bucket is required")
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "staging"
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 50000
+	}
+	gcs, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("storage.NewClient: %w", err)
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	return &loadSink{
+		opts: opts,
+		bq:   bq.client,
+		gcs:  gcs,
+		// workers run in parallel, the run keeps their staged files apart.
+		run: time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix),
+	}, nil
+}
+
+func (s *loadSink) Write(entry Entry) error {
+	return s.WriteBatch([]Entry{entry})
+}
+
+func (s *loadSink) WriteBatch(entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range entries {
+		s.batch = append(s.batch, entry)
+		if len(s.batch) >= s.opts.MaxEntries {
+			if err := s.stage(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// stage uploads the buffered entries as a NDJSON file to the staging prefix.
+func (s *loadSink) stage() error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	data, err := encodeLoadRows(s.batch)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("%s/%s.%s/%s-%05d.json", s.opts.Prefix, s.opts.Dataset, s.opts.Table, s.run, s.counter)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	wc := s.gcs.Bucket(s.opts.Bucket).Object(path).NewWriter(ctx)
+	wc.ContentType = "application/x-ndjson"
+	if _, err := wc.Write(data); err != nil {
+		//nolint
+		wc.Close()
+		return fmt.Errorf("Object(%q).Writer: %w", path, err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("Object(%q).Close: %w", path, err)
+	}
+	s.counter++
+	s.staged = append(s.staged, path)
+	s.batch = nil
+	return nil
+}
+
+// encodeLoadRows encodes the entries as NDJSON keyed by the column names of the table, the same values the
+// inserter saves.
+func encodeLoadRows(entries []Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		row, _, err := entrySaver{entry: entry}.Save()
+		if err != nil {
+			return nil, fmt.Errorf("failed to save entry %d %w", entry.LogIndex, err)
+		}
+		line, err := json.Marshal(row)
+		if err != nil {
+			return nil, fmt.Errorf("json.Marshal: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// Flush stages the buffered entries, loads all the staged files into the table and deletes them.
+func (s *loadSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.stage(); err != nil {
+		return err
+	}
+	if len(s.staged) == 0 {
+		return nil
+	}
+	uris := make([]string, 0, len(s.staged))
+	for _, path := range s.staged {
+		uris = append(uris, fmt.Sprintf("gs://%s/%s", s.opts.Bucket, path))
+	}
+	ref := bigquery.NewGCSReference(uris...)
+	ref.SourceFormat = bigquery.JSON
+	ref.Schema = entrySchema
+	loader := s.bq.Dataset(s.opts.Dataset).Table(s.opts.Table).LoaderFrom(ref)
+	loader.WriteDisposition = bigquery.WriteAppend
+	loader.CreateDisposition = bigquery.CreateIfNeeded
+	loader.SchemaUpdateOptions = []string{"ALLOW_FIELD_ADDITION", "ALLOW_FIELD_RELAXATION"}
+	ctx := context.Background()
+	job, err := loader.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("Loader.Run: %w", err)
+	}
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("Job.Wait: %w", err)
+	}
+	if err := status.Err(); err != nil {
+		// the staged files are kept so the load can be retried.
+		return fmt.Errorf("load job %s failed %w", job.ID(), err)
+	}
+	var errs []error
+	for _, path := range s.staged {
+		if err := s.gcs.Bucket(s.opts.Bucket).Object(path).Delete(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("Object(%q).Delete: %w", path, err))
+		}
+	}
+	s.staged = nil
+	return errors.Join(errs...)
+}
+
+// Close loads the buffered entries and closes the GCS client, the BigQuery client is closed with the repository.
+func (s *loadSink) Close() error {
+	err := s.Flush()
+	if cerr := s.gcs.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
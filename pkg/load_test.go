@@ -0,0 +1,34 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEncodeLoadRows(t *testing.T) {
+	date := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	data, err := encodeLoadRows([]Entry{
+		{LogIndex: 1, DecoderVersion: 1, Date: date, Kind: Kind{Kind: "hashedrekord"}},
+		{LogIndex: 2, ParseError: "error decoding base64"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	var row map[string]interface{}
+	if err := json.Unmarshal(lines[0], &row); err != nil {
+		t.Fatal(err)
+	}
+	// the rows are keyed by the column names, not by the json tags of Entry
+	if row["LogIndex"] != float64(1) || row["DecoderVersion"] != float64(1) || row["Date"] != "2023-01-02T03:04:05Z" {
+		t.Errorf("row = %v", row)
+	}
+	if kind, ok := row["Kind"].(map[string]interface{}); !ok || kind["Kind"] != "hashedrekord" {
+		t.Errorf("row kind = %v", row["Kind"])
+	}
+}
@@ -0,0 +1,692 @@
+package pkg
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+)
+
+// migrationsTable is the table of the dataset the applied migrations are recorded in.
+const migrationsTable = "schema_migrations"
+
+// migrationSchemas are the schemas the migrations were released with, in the format of the bq tool.
+//
+//go:embed migrations/*.json
+var migrationSchemas embed.FS
+
+// bigQueryMigration is a numbered change of the entries table, its version is its position in
+// bigQueryMigrations. The columns of schema are diffed against the table, columns the table does not have
+// are added and columns whose type changed are rewritten.
+type bigQueryMigration struct {
+	description string
+	// schema returns the schema of the table after the migration, it must not depend on Entry.
+	schema func() (bigquery.Schema, error)
+	// partitioned migrates the table to the partitioning and clustering of entryPartitioning and entryClustering.
+	partitioned bool
+}
+
+// bigQueryMigrations are applied in order. Never change a migration that has been released, append a new one
+// instead. The schemas are frozen, the first one is the table the schema command used to create and every later
+// one adds its columns to the one before, so a later change of Entry does not change what a migration does. A
+// change of Entry that changes its schema needs a new migration, the plan fails until the last one matches
+// entrySchema.
+var bigQueryMigrations = []bigQueryMigration{
+	{description: "columns of pkg.Entry created by the schema command", schema: migrationBaseline},
+	{description: "PublicKeyInfo of the signatures", schema: migrationPublicKeyInfo},
+	{description: "PGPKeys of the rekord signatures", schema: migrationPGPKeys},
+	{description: "Keys of the rekord signatures", schema: migrationKeys},
+	{description: "ParseError and Body of the entries that could not be decoded", schema: migrationParseError},
+	{description: "DecoderVersion of the entries", schema: migrationDecoderVersion},
+	{
+		description: "IntegratedAt, KindName and Issuer columns, partitioned by IntegratedAt and clustered by KindName and Issuer",
+		schema:      migrationDerived,
+		partitioned: true,
+	},
+}
+
+var (
+	migrationBaseline = frozenSchema("0001_baseline.json")
+
+	migrationPublicKeyInfo = addColumns(migrationBaseline, map[string]bigquery.Schema{
+		"Rekord.Signature":       {publicKeyInfoColumn()},
+		"HashedRekord.Signature": {publicKeyInfoColumn()},
+		"Intoto.Signature":       {publicKeyInfoColumn()},
+	})
+
+	migrationPGPKeys = addColumns(migrationPublicKeyInfo, map[string]bigquery.Schema{
+		"Rekord.Signature": {{Name: "PGPKeys", Type: bigquery.RecordFieldType, Repeated: true, Schema: bigquery.Schema{
+			{Name: "Fingerprint", Type: bigquery.StringFieldType},
+			{Name: "KeyID", Type: bigquery.StringFieldType},
+			{Name: "Algorithm", Type: bigquery.StringFieldType},
+			{Name: "Bits", Type: bigquery.IntegerFieldType},
+			{Name: "CreationTime", Type: bigquery.TimestampFieldType},
+			{Name: "ExpirationTime", Type: bigquery.TimestampFieldType},
+			{Name: "Subkeys", Type: bigquery.RecordFieldType, Repeated: true, Schema: bigquery.Schema{
+				{Name: "Fingerprint", Type: bigquery.StringFieldType},
+				{Name: "KeyID", Type: bigquery.StringFieldType},
+				{Name: "Algorithm", Type: bigquery.StringFieldType},
+				{Name: "Bits", Type: bigquery.IntegerFieldType},
+				{Name: "CreationTime", Type: bigquery.TimestampFieldType},
+				{Name: "ExpirationTime", Type: bigquery.TimestampFieldType},
+			}},
+			{Name: "UIDs", Type: bigquery.RecordFieldType, Repeated: true, Schema: bigquery.Schema{
+				{Name: "Name", Type: bigquery.StringFieldType},
+				{Name: "Comment", Type: bigquery.StringFieldType},
+				{Name: "Email", Type: bigquery.StringFieldType},
+			}},
+		}}},
+	})
+
+	migrationKeys = addColumns(migrationPGPKeys, map[string]bigquery.Schema{
+		"Rekord.Signature": {{Name: "Keys", Type: bigquery.RecordFieldType, Repeated: true, Schema: bigquery.Schema{
+			{Name: "Format", Type: bigquery.StringFieldType},
+			{Name: "Algorithm", Type: bigquery.StringFieldType},
+			{Name: "Fingerprint", Type: bigquery.StringFieldType},
+			{Name: "KeyID", Type: bigquery.StringFieldType},
+			{Name: "Identities", Type: bigquery.StringFieldType, Repeated: true},
+		}}},
+	})
+
+	migrationParseError = addColumns(migrationKeys, map[string]bigquery.Schema{
+		"": {
+			{Name: "ParseError", Type: bigquery.StringFieldType},
+			{Name: "Body", Type: bigquery.StringFieldType},
+		},
+	})
+
+	migrationDecoderVersion = addColumns(migrationParseError, map[string]bigquery.Schema{
+		"": {{Name: "DecoderVersion", Type: bigquery.IntegerFieldType}},
+	})
+
+	migrationDerived = addColumns(migrationDecoderVersion, map[string]bigquery.Schema{
+		"": {
+			{Name: "IntegratedAt", Type: bigquery.TimestampFieldType, Description: "integrated time of the entry"},
+			{Name: "KindName", Type: bigquery.StringFieldType, Description: "kind of the entry, the same as Kind.Kind"},
+			{Name: "Issuer", Type: bigquery.StringFieldType, Description: "issuer organization, or common name, of the certificate"},
+		},
+	})
+)
+
+// publicKeyInfoColumn returns the PublicKeyInfo record, every signature got its own copy.
+func publicKeyInfoColumn() *bigquery.FieldSchema {
+	return &bigquery.FieldSchema{Name: "PublicKeyInfo", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+		{Name: "Algorithm", Type: bigquery.StringFieldType},
+		{Name: "Curve", Type: bigquery.StringFieldType},
+		{Name: "Bits", Type: bigquery.IntegerFieldType},
+		{Name: "FingerprintSHA256", Type: bigquery.StringFieldType},
+	}}
+}
+
+// frozenSchema returns the schema of the file in the migrations directory.
+func frozenSchema(name string) func() (bigquery.Schema, error) {
+	return func() (bigquery.Schema, error) {
+		data, err := migrationSchemas.ReadFile("migrations/" + name)
+		if err != nil {
+			return nil, err
+		}
+		s, err := bigquery.SchemaFromJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema %s %w", name, err)
+		}
+		return s, nil
+	}
+}
+
+// addColumns returns the schema of base with the columns appended to the records they are keyed by, the
+// path of the record with its names separated by dots or "" for the top level columns.
+func addColumns(base func() (bigquery.Schema, error), columns map[string]bigquery.Schema) func() (bigquery.Schema, error) {
+	return func() (bigquery.Schema, error) {
+		s, err := base()
+		if err != nil {
+			return nil, err
+		}
+		for path, add := range columns {
+			if path == "" {
+				s = append(s, add...)
+				continue
+			}
+			record, err := findRecord(s, strings.Split(path, "."))
+			if err != nil {
+				return nil, fmt.Errorf("record %s: %w", path, err)
+			}
+			record.Schema = append(record.Schema, add...)
+		}
+		return s, nil
+	}
+}
+
+// findRecord returns the record column at the path of names.
+func findRecord(s bigquery.Schema, names []string) (*bigquery.FieldSchema, error) {
+	for _, f := range s {
+		if !strings.EqualFold(f.Name, names[0]) {
+			continue
+		}
+		if f.Type != bigquery.RecordFieldType {
+			return nil, fmt.Errorf("column %s is %s", f.Name, f.Type)
+		}
+		if len(names) == 1 {
+			return f, nil
+		}
+		return findRecord(f.Schema, names[1:])
+	}
+	return nil, fmt.Errorf("column %s does not exist", names[0])
+}
+
+// ChangeKind is the kind of a ColumnChange.
+type ChangeKind string
+
+const (
+	// ColumnAdded is a column of the schema the table does not have.
+	ColumnAdded ChangeKind = "add"
+	// ColumnRelaxed is a REQUIRED column that becomes NULLABLE.
+	ColumnRelaxed ChangeKind = "relax"
+	// ColumnTypeChanged is a column whose type changed, the table is rewritten with the values cast to the new type.
+	ColumnTypeChanged ChangeKind = "type"
+	// ColumnKept is a column of the table that is not in the schema, BigQuery keeps it.
+	ColumnKept ChangeKind = "keep"
+	// ColumnRemoved is a column of a schema that the schema of the entries does not have, see DiffEntrySchema.
+	ColumnRemoved ChangeKind = "remove"
+	// ColumnIncompatible is a change between a record and another type or between a repeated and a single
+	// column, BigQuery cannot make it.
+	ColumnIncompatible ChangeKind = "incompatible"
+)
+
+// ColumnChange is a difference between the columns of the table and the schema of a migration.
+type ColumnChange struct {
+	// Column is the path of the column, nested columns are separated by dots.
+	Column string
+	Kind   ChangeKind
+	// From and To are the types of the column in the table and in the schema.
+	From string
+	To   string
+}
+
+func (c ColumnChange) String() string {
+	switch c.Kind {
+	case ColumnAdded:
+		return fmt.Sprintf("+ %s %s", c.Column, c.To)
+	case ColumnRelaxed:
+		return fmt.Sprintf("~ %s REQUIRED -> NULLABLE", c.Column)
+	case ColumnTypeChanged:
+		return fmt.Sprintf("~ %s %s -> %s, rewrites the table", c.Column, c.From, c.To)
+	case ColumnKept:
+		return fmt.Sprintf("= %s %s, not in the schema, kept", c.Column, c.From)
+	case ColumnRemoved:
+		return fmt.Sprintf("- %s %s, not in the schema", c.Column, c.From)
+	case ColumnIncompatible:
+		return fmt.Sprintf("! %s %s -> %s, incompatible", c.Column, c.From, c.To)
+	}
+	return fmt.Sprintf("? %s %s -> %s", c.Column, c.From, c.To)
+}
+
+// Breaking returns whether readers of the old schema can break on the change. Added and relaxed columns are
+// additive and kept columns do not change, every other change is breaking.
+func (c ColumnChange) Breaking() bool {
+	return c.Kind != ColumnAdded && c.Kind != ColumnRelaxed && c.Kind != ColumnKept
+}
+
+// MigrationStep is a migration that has not been applied to the table.
+type MigrationStep struct {
+	Version     int
+	Description string
+	Changes     []ColumnChange
+	// Partition is set when the step copies the table to partition and cluster it.
+	Partition bool
+	// schema is the schema of the table after the step.
+	schema bigquery.Schema
+}
+
+// rewrite returns whether the step changes the type of a column.
+func (s MigrationStep) rewrite() bool {
+	for _, c := range s.Changes {
+		if c.Kind == ColumnTypeChanged {
+			return true
+		}
+	}
+	return false
+}
+
+// MigrationPlan is the list of migrations that have not been applied to the table yet.
+type MigrationPlan struct {
+	Dataset string
+	Table   string
+	// Version is the version of the table when the plan was made.
+	Version int
+	// Exists is false when the table does not exist yet, it is created with the schema of the last step.
+	Exists bool
+	Steps  []MigrationStep
+}
+
+func (p MigrationPlan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s.%s is at version %d", p.Dataset, p.Table, p.Version)
+	if !p.Exists {
+		b.WriteString(", the table does not exist and is created")
+	}
+	if len(p.Steps) == 0 {
+		b.WriteString(", it is up to date\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, ", %d pending migrations\n", len(p.Steps))
+	for _, step := range p.Steps {
+		fmt.Fprintf(&b, "%d %s\n", step.Version, step.Description)
+		for _, c := range step.Changes {
+			fmt.Fprintf(&b, "  %s\n", c)
+		}
+		if step.Partition {
+			fmt.Fprintf(&b, "  partition by %s, cluster by %s", entryPartitioning.Field, strings.Join(entryClustering.Fields, ", "))
+			if p.Exists {
+				b.WriteString(", copies the table")
+			}
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// PlanMigrations returns the migrations that have not been applied to the table and the column changes they make.
+func (b *BigQuery) PlanMigrations(dataset, table string) (MigrationPlan, error) {
+	if dataset == "" {
+		return MigrationPlan{}, fmt.Errorf("dataset is required")
+	}
+	if table == "" {
+		return MigrationPlan{}, fmt.Errorf("table is required")
+	}
+	version, err := b.migrationVersion(dataset, table)
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+	plan := MigrationPlan{Dataset: dataset, Table: table, Version: version, Exists: true}
+	md, err := b.client.Dataset(dataset).Table(table).Metadata(context.Background())
+	if isNotFound(err) {
+		plan.Exists = false
+		md, err = &bigquery.TableMetadata{}, nil
+	}
+	if err != nil {
+		return MigrationPlan{}, fmt.Errorf("Table.Metadata: %w", err)
+	}
+	return planMigrations(plan, bigQueryMigrations, md.Schema, md.TimePartitioning != nil)
+}
+
+// planMigrations adds the steps of the migrations after plan.Version to the plan, live is the schema of the table.
+func planMigrations(plan MigrationPlan, migrations []bigQueryMigration, live bigquery.Schema,
+	partitioned bool) (MigrationPlan, error) {
+	if plan.Version > len(migrations) {
+		return plan, fmt.Errorf("the table is at version %d, this build only knows %d migrations", plan.Version,
+			len(migrations))
+	}
+	current := live
+	for i := plan.Version; i < len(migrations); i++ {
+		target, err := migrations[i].schema()
+		if err != nil {
+			return plan, fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		changes := diffSchemas("", current, target)
+		for _, c := range changes {
+			if c.Kind == ColumnIncompatible {
+				return plan, fmt.Errorf("migration %d: column %s cannot change from %s to %s", i+1, c.Column, c.From, c.To)
+			}
+		}
+		current = mergeSchema(current, target, true)
+		plan.Steps = append(plan.Steps, MigrationStep{
+			Version:     i + 1,
+			Description: migrations[i].description,
+			Changes:     changes,
+			Partition:   migrations[i].partitioned && !partitioned,
+			schema:      current,
+		})
+		partitioned = partitioned || migrations[i].partitioned
+	}
+	if len(plan.Steps) == 0 && !plan.Exists {
+		return plan, fmt.Errorf("the table does not exist but version %d is recorded for it", plan.Version)
+	}
+	if len(plan.Steps) == 0 {
+		for _, c := range diffSchemas("", live, entrySchema) {
+			if c.Kind != ColumnKept {
+				return plan, fmt.Errorf("the table is up to date but differs from pkg.Entry (%s), add a migration", c)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// ApplyMigrations applies the steps of the plan in order and records each of them in the schema_migrations
+// table of the dataset. It fails if the table was migrated since the plan was made. Steps that rewrite or
+// partition the table replace its rows, the writers have to be stopped while they run.
+func (b *BigQuery) ApplyMigrations(plan MigrationPlan) error {
+	if len(plan.Steps) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	if err := b.createMigrationsTable(plan.Dataset); err != nil {
+		return err
+	}
+	version, err := b.migrationVersion(plan.Dataset, plan.Table)
+	if err != nil {
+		return err
+	}
+	if version != plan.Version {
+		return fmt.Errorf("the table was migrated to version %d after the plan was made at version %d", version, plan.Version)
+	}
+	ref := b.client.Dataset(plan.Dataset).Table(plan.Table)
+	if !plan.Exists {
+		md := &bigquery.TableMetadata{Schema: plan.Steps[len(plan.Steps)-1].schema}
+		for _, step := range plan.Steps {
+			if step.Partition {
+				md.TimePartitioning, md.Clustering = entryPartitioning, entryClustering
+			}
+		}
+		if err := ref.Create(ctx, md); err != nil {
+			return fmt.Errorf("Table.Create: %w", err)
+		}
+	}
+	for _, step := range plan.Steps {
+		if plan.Exists {
+			if err := b.applyStep(plan.Dataset, plan.Table, step); err != nil {
+				return fmt.Errorf("failed to apply migration %d: %w", step.Version, err)
+			}
+		}
+		if err := b.recordStep(plan.Dataset, plan.Table, step); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", step.Version, err)
+		}
+	}
+	return nil
+}
+
+// applyStep adds and relaxes the columns of the step, rewrites the table when a column type changed and
+// partitions it.
+func (b *BigQuery) applyStep(dataset, table string, step MigrationStep) error {
+	ctx := context.Background()
+	ref := b.client.Dataset(dataset).Table(table)
+	md, err := ref.Metadata(ctx)
+	if err != nil {
+		return fmt.Errorf("Table.Metadata: %w", err)
+	}
+	// the columns whose type changed keep their type until the rewrite.
+	added := mergeSchema(md.Schema, step.schema, false)
+	if _, err := ref.Update(ctx, bigquery.TableMetadataToUpdate{Schema: added}, md.ETag); err != nil {
+		return fmt.Errorf("Table.Update: %w", err)
+	}
+	if step.rewrite() {
+		query, err := rewriteQuery(b.tableRef(dataset, table), added, step.schema)
+		if err != nil {
+			return err
+		}
+		q := b.client.Query(query)
+		q.Dst = ref
+		q.WriteDisposition = bigquery.WriteTruncate
+		q.TimePartitioning, q.Clustering = md.TimePartitioning, md.Clustering
+		if err := waitJob(q.Run(ctx)); err != nil {
+			return fmt.Errorf("failed to rewrite the table %w", err)
+		}
+	}
+	if step.Partition {
+		return b.PartitionTable(dataset, table)
+	}
+	return nil
+}
+
+// createMigrationsTable creates the schema_migrations table of the dataset if it does not exist.
+func (b *BigQuery) createMigrationsTable(dataset string) error {
+	ctx := context.Background()
+	ref := b.client.Dataset(dataset).Table(migrationsTable)
+	_, err := ref.Metadata(ctx)
+	if !isNotFound(err) {
+		return err
+	}
+	err = ref.Create(ctx, &bigquery.TableMetadata{Schema: bigquery.Schema{
+		{Name: "TableName", Type: bigquery.StringFieldType, Required: true},
+		{Name: "Version", Type: bigquery.IntegerFieldType, Required: true},
+		{Name: "Description", Type: bigquery.StringFieldType},
+		{Name: "Changes", Type: bigquery.StringFieldType, Repeated: true},
+		{Name: "AppliedAt", Type: bigquery.TimestampFieldType, Required: true},
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to create %s %w", migrationsTable, err)
+	}
+	return nil
+}
+
+// migrationVersion returns the last migration recorded for the table, 0 if there is none.
+func (b *BigQuery) migrationVersion(dataset, table string) (int, error) {
+	ctx := context.Background()
+	_, err := b.client.Dataset(dataset).Table(migrationsTable).Metadata(ctx)
+	if isNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Table.Metadata: %w", err)
+	}
+	q := b.client.Query(fmt.Sprintf("SELECT IFNULL(MAX(Version), 0) FROM %s WHERE TableName = @table",
+		b.tableRef(dataset, migrationsTable)))
+	q.Parameters = []bigquery.QueryParameter{{Name: "table", Value: table}}
+	it, err := q.Read(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("Query.Read: %w", err)
+	}
+	var values []bigquery.Value
+	if err := it.Next(&values); err != nil && !errors.Is(err, iterator.Done) {
+		return 0, fmt.Errorf("Iterator.Next: %w", err)
+	}
+	if len(values) == 0 {
+		return 0, nil
+	}
+	return int(values[0].(int64)), nil
+}
+
+// recordStep records the applied step in the schema_migrations table.
+func (b *BigQuery) recordStep(dataset, table string, step MigrationStep) error {
+	changes := make([]string, 0, len(step.Changes))
+	for _, c := range step.Changes {
+		changes = append(changes, c.String())
+	}
+	q := b.client.Query(fmt.Sprintf("INSERT INTO %s (TableName, Version, Description, Changes, AppliedAt) "+
+		"VALUES (@table, @version, @description, @changes, CURRENT_TIMESTAMP())", b.tableRef(dataset, migrationsTable)))
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "table", Value: table},
+		{Name: "version", Value: step.Version},
+		{Name: "description", Value: step.Description},
+		{Name: "changes", Value: changes},
+	}
+	return waitJob(q.Run(context.Background()))
+}
+
+// isNotFound returns whether the error is a 404 of the BigQuery API.
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// columnType returns the type of the column as it is shown in the plan.
+func columnType(f *bigquery.FieldSchema) string {
+	if f.Repeated {
+		return "REPEATED " + string(f.Type)
+	}
+	return string(f.Type)
+}
+
+// fieldsByName returns the fields of the schema by their lower case name, BigQuery column names are case insensitive.
+func fieldsByName(s bigquery.Schema) map[string]*bigquery.FieldSchema {
+	fields := make(map[string]*bigquery.FieldSchema, len(s))
+	for _, f := range s {
+		fields[strings.ToLower(f.Name)] = f
+	}
+	return fields
+}
+
+// diffSchemas returns the changes that turn the columns of from into the columns of to.
+func diffSchemas(path string, from, to bigquery.Schema) []ColumnChange {
+	var changes []ColumnChange
+	live := fieldsByName(from)
+	for _, f := range to {
+		column := path + f.Name
+		l, ok := live[strings.ToLower(f.Name)]
+		switch {
+		case !ok:
+			changes = append(changes, ColumnChange{Column: column, Kind: ColumnAdded, To: columnType(f)})
+		case l.Repeated != f.Repeated,
+			l.Type != f.Type && (l.Type == bigquery.RecordFieldType || f.Type == bigquery.RecordFieldType):
+			changes = append(changes, ColumnChange{Column: column, Kind: ColumnIncompatible, From: columnType(l),
+				To: columnType(f)})
+		case l.Type != f.Type:
+			changes = append(changes, ColumnChange{Column: column, Kind: ColumnTypeChanged, From: columnType(l),
+				To: columnType(f)})
+		case l.Type == bigquery.RecordFieldType:
+			changes = append(changes, diffSchemas(column+".", l.Schema, f.Schema)...)
+		}
+		if ok && l.Required && !f.Required {
+			changes = append(changes, ColumnChange{Column: column, Kind: ColumnRelaxed, From: columnType(l), To: columnType(f)})
+		}
+	}
+	target := fieldsByName(to)
+	for _, l := range from {
+		if _, ok := target[strings.ToLower(l.Name)]; !ok {
+			changes = append(changes, ColumnChange{Column: path + l.Name, Kind: ColumnKept, From: columnType(l)})
+		}
+	}
+	return changes
+}
+
+// mergeSchema returns the columns of from, in their order, followed by the columns of to that from does not
+// have. Columns of both are relaxed as in to, they take the type of to when types is set and keep their
+// own otherwise.
+func mergeSchema(from, to bigquery.Schema, types bool) bigquery.Schema {
+	target := fieldsByName(to)
+	merged := make(bigquery.Schema, 0, len(to))
+	for _, l := range from {
+		f, ok := target[strings.ToLower(l.Name)]
+		if !ok {
+			merged = append(merged, l)
+			continue
+		}
+		field := *l
+		field.Required = l.Required && f.Required
+		if l.Type == bigquery.RecordFieldType && f.Type == bigquery.RecordFieldType {
+			field.Schema = mergeSchema(l.Schema, f.Schema, types)
+		} else if types {
+			field.Type = f.Type
+		}
+		merged = append(merged, &field)
+	}
+	live := fieldsByName(from)
+	for _, f := range to {
+		if _, ok := live[strings.ToLower(f.Name)]; !ok {
+			merged = append(merged, f)
+		}
+	}
+	return merged
+}
+
+// rewriteQuery returns the query that selects the rows of the table with the columns whose type differs
+// between from and to cast to the type of to.
+func rewriteQuery(tableRef string, from, to bigquery.Schema) (string, error) {
+	target := fieldsByName(to)
+	var replaced []string
+	for _, l := range from {
+		f, ok := target[strings.ToLower(l.Name)]
+		if !ok || !typeChanged(l, f) {
+			continue
+		}
+		expr, err := rewriteExpr(l.Name, l, f)
+		if err != nil {
+			return "", err
+		}
+		replaced = append(replaced, fmt.Sprintf("%s AS %s", expr, l.Name))
+	}
+	if len(replaced) == 0 {
+		return "", fmt.Errorf("no column changes its type")
+	}
+	return fmt.Sprintf("SELECT * REPLACE (%s) FROM %s", strings.Join(replaced, ", "), tableRef), nil
+}
+
+// typeChanged returns whether the type of the column, or of one of its nested columns, differs.
+func typeChanged(from, to *bigquery.FieldSchema) bool {
+	if from.Type != to.Type {
+		return true
+	}
+	if from.Type != bigquery.RecordFieldType {
+		return false
+	}
+	target := fieldsByName(to.Schema)
+	for _, l := range from.Schema {
+		if f, ok := target[strings.ToLower(l.Name)]; ok && typeChanged(l, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteExpr returns the expression that converts expr, a value of the column from, to the type of to.
+// Records are rebuilt field by field, repeated records element by element.
+func rewriteExpr(expr string, from, to *bigquery.FieldSchema) (string, error) {
+	if from.Type != bigquery.RecordFieldType {
+		value := expr
+		if from.Repeated {
+			value = "v"
+		}
+		cast, err := castExpr(value, from.Type, to.Type)
+		if err != nil {
+			return "", err
+		}
+		if from.Repeated {
+			return fmt.Sprintf("ARRAY(SELECT %s FROM UNNEST(%s) AS v WITH OFFSET AS o ORDER BY o)", cast, expr), nil
+		}
+		return cast, nil
+	}
+	record := expr
+	if from.Repeated {
+		record = "r"
+	}
+	target := fieldsByName(to.Schema)
+	fields := make([]string, 0, len(from.Schema))
+	for _, l := range from.Schema {
+		value := record + "." + l.Name
+		if f, ok := target[strings.ToLower(l.Name)]; ok && typeChanged(l, f) {
+			var err error
+			if value, err = rewriteExpr(value, l, f); err != nil {
+				return "", err
+			}
+		}
+		fields = append(fields, fmt.Sprintf("%s AS %s", value, l.Name))
+	}
+	if from.Repeated {
+		return fmt.Sprintf("ARRAY(SELECT AS STRUCT %s FROM UNNEST(%s) AS r WITH OFFSET AS o ORDER BY o)",
+			strings.Join(fields, ", "), expr), nil
+	}
+	return fmt.Sprintf("IF(%s IS NULL, NULL, STRUCT(%s))", expr, strings.Join(fields, ", ")), nil
+}
+
+// sqlTypes are the SQL names of the column types that differ from them.
+var sqlTypes = map[bigquery.FieldType]string{
+	bigquery.IntegerFieldType: "INT64",
+	bigquery.FloatFieldType:   "FLOAT64",
+	bigquery.BooleanFieldType: "BOOL",
+}
+
+// castExpr returns the expression that converts expr from one column type to another. Integers and timestamps
+// are converted as Unix seconds, the way IntegratedTime is stored. The cast fails the query when a value
+// cannot be converted.
+func castExpr(expr string, from, to bigquery.FieldType) (string, error) {
+	switch {
+	case from == to:
+		return expr, nil
+	case from == bigquery.IntegerFieldType && to == bigquery.TimestampFieldType:
+		return fmt.Sprintf("TIMESTAMP_SECONDS(%s)", expr), nil
+	case from == bigquery.TimestampFieldType && to == bigquery.IntegerFieldType:
+		return fmt.Sprintf("UNIX_SECONDS(%s)", expr), nil
+	case from == bigquery.RecordFieldType || to == bigquery.RecordFieldType:
+		return "", fmt.Errorf("cannot cast %s to %s", from, to)
+	}
+	name, ok := sqlTypes[to]
+	if !ok {
+		name = string(to)
+	}
+	return fmt.Sprintf("CAST(%s AS %s)", expr, name), nil
+}
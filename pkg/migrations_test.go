@@ -0,0 +1,243 @@
+package pkg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+var testLiveSchema = bigquery.Schema{
+	{Name: "IntegratedTime", Type: bigquery.IntegerFieldType, Required: true},
+	{Name: "Kind", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+		{Name: "Kind", Type: bigquery.StringFieldType},
+	}},
+	{Name: "Keys", Type: bigquery.RecordFieldType, Repeated: true, Schema: bigquery.Schema{
+		{Name: "Size", Type: bigquery.StringFieldType},
+		{Name: "Fingerprint", Type: bigquery.StringFieldType},
+	}},
+	{Name: "Legacy", Type: bigquery.StringFieldType},
+}
+
+var testTargetSchema = bigquery.Schema{
+	{Name: "integratedtime", Type: bigquery.TimestampFieldType},
+	{Name: "Kind", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+		{Name: "Kind", Type: bigquery.StringFieldType},
+		{Name: "APIVersion", Type: bigquery.StringFieldType},
+	}},
+	{Name: "Keys", Type: bigquery.RecordFieldType, Repeated: true, Schema: bigquery.Schema{
+		{Name: "Size", Type: bigquery.IntegerFieldType},
+		{Name: "Fingerprint", Type: bigquery.StringFieldType},
+	}},
+	{Name: "Issuer", Type: bigquery.StringFieldType},
+}
+
+func TestDiffSchemas(t *testing.T) {
+	got := diffSchemas("", testLiveSchema, testTargetSchema)
+	want := []string{
+		"~ integratedtime INTEGER -> TIMESTAMP, rewrites the table",
+		"~ integratedtime REQUIRED -> NULLABLE",
+		"+ Kind.APIVersion STRING",
+		"~ Keys.Size STRING -> INTEGER, rewrites the table",
+		"+ Issuer STRING",
+		"= Legacy STRING, not in the schema, kept",
+	}
+	var lines []string
+	for _, c := range got {
+		lines = append(lines, c.String())
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("diffSchemas() =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDiffSchemasIncompatible(t *testing.T) {
+	tests := []struct {
+		name string
+		from *bigquery.FieldSchema
+		to   *bigquery.FieldSchema
+	}{
+		{
+			name: "repeated",
+			from: &bigquery.FieldSchema{Name: "A", Type: bigquery.StringFieldType},
+			to:   &bigquery.FieldSchema{Name: "A", Type: bigquery.StringFieldType, Repeated: true},
+		},
+		{
+			name: "record",
+			from: &bigquery.FieldSchema{Name: "A", Type: bigquery.StringFieldType},
+			to:   &bigquery.FieldSchema{Name: "A", Type: bigquery.RecordFieldType},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffSchemas("", bigquery.Schema{tt.from}, bigquery.Schema{tt.to})
+			if len(got) != 1 || got[0].Kind != ColumnIncompatible || !got[0].Breaking() {
+				t.Errorf("diffSchemas() = %v, want an incompatible change", got)
+			}
+		})
+	}
+}
+
+func TestMergeSchema(t *testing.T) {
+	added := mergeSchema(testLiveSchema, testTargetSchema, false)
+	if added[0].Type != bigquery.IntegerFieldType || added[0].Required {
+		t.Errorf("IntegratedTime = %+v, want a relaxed INTEGER", added[0])
+	}
+	if added[2].Schema[0].Type != bigquery.StringFieldType {
+		t.Errorf("Keys.Size = %s, want STRING until the rewrite", added[2].Schema[0].Type)
+	}
+	var names []string
+	for _, f := range added {
+		names = append(names, f.Name)
+	}
+	// the columns keep their order, the new ones are appended
+	if want := []string{"IntegratedTime", "Kind", "Keys", "Legacy", "Issuer"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("columns = %v, want %v", names, want)
+	}
+	if len(added[1].Schema) != 2 {
+		t.Errorf("Kind = %+v, want APIVersion added", added[1].Schema)
+	}
+	typed := mergeSchema(testLiveSchema, testTargetSchema, true)
+	if typed[0].Type != bigquery.TimestampFieldType || typed[2].Schema[0].Type != bigquery.IntegerFieldType {
+		t.Errorf("typed columns = %s, %s", typed[0].Type, typed[2].Schema[0].Type)
+	}
+	if testLiveSchema[0].Type != bigquery.IntegerFieldType || !testLiveSchema[0].Required {
+		t.Error("mergeSchema() changed its input")
+	}
+}
+
+func TestRewriteQuery(t *testing.T) {
+	from := mergeSchema(testLiveSchema, testTargetSchema, false)
+	to := mergeSchema(testLiveSchema, testTargetSchema, true)
+	got, err := rewriteQuery("`p.d.t`", from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SELECT * REPLACE (TIMESTAMP_SECONDS(IntegratedTime) AS IntegratedTime, " +
+		"ARRAY(SELECT AS STRUCT CAST(r.Size AS INT64) AS Size, r.Fingerprint AS Fingerprint " +
+		"FROM UNNEST(Keys) AS r WITH OFFSET AS o ORDER BY o) AS Keys) FROM `p.d.t`"
+	if got != want {
+		t.Errorf("rewriteQuery() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPlanMigrations(t *testing.T) {
+	migrations := []bigQueryMigration{
+		{description: "first", schema: func() (bigquery.Schema, error) { return testLiveSchema, nil }},
+		{description: "second", schema: func() (bigquery.Schema, error) { return testTargetSchema, nil }, partitioned: true},
+	}
+	tests := []struct {
+		name          string
+		version       int
+		exists        bool
+		live          bigquery.Schema
+		partitioned   bool
+		wantVersions  []int
+		wantPartition bool
+		wantRewrite   bool
+		wantErr       bool
+	}{
+		{name: "new table", wantVersions: []int{1, 2}, wantPartition: true, wantRewrite: true},
+		{name: "unversioned table", exists: true, live: testLiveSchema, wantVersions: []int{1, 2}, wantPartition: true,
+			wantRewrite: true},
+		{name: "partitioned table", version: 1, exists: true, live: testLiveSchema, partitioned: true,
+			wantVersions: []int{2}, wantRewrite: true},
+		{name: "newer table", version: 3, exists: true, wantErr: true},
+		{name: "deleted table", version: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planMigrations(MigrationPlan{Dataset: "d", Table: "t", Version: tt.version, Exists: tt.exists},
+				migrations, tt.live, tt.partitioned)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("planMigrations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var versions []int
+			partition, rewrite := false, false
+			for _, step := range plan.Steps {
+				versions = append(versions, step.Version)
+				partition = partition || step.Partition
+				rewrite = rewrite || step.rewrite()
+			}
+			if !reflect.DeepEqual(versions, tt.wantVersions) || partition != tt.wantPartition || rewrite != tt.wantRewrite {
+				t.Errorf("steps %v, partition %v, rewrite %v, want %v, %v, %v\n%s", versions, partition, rewrite,
+					tt.wantVersions, tt.wantPartition, tt.wantRewrite, plan)
+			}
+			if last := plan.Steps[len(plan.Steps)-1].schema; last[0].Type != bigquery.TimestampFieldType {
+				t.Errorf("schema after the plan has IntegratedTime %s", last[0].Type)
+			}
+		})
+	}
+}
+
+func TestBigQueryMigrations(t *testing.T) {
+	// a fresh table ends up with the schema the rows are saved with
+	plan, err := planMigrations(MigrationPlan{}, bigQueryMigrations, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := diffSchemas("", plan.Steps[len(plan.Steps)-1].schema, entrySchema); len(changes) != 0 {
+		t.Errorf("schema after the migrations differs from entrySchema: %v", changes)
+	}
+}
+
+func TestCastExpr(t *testing.T) {
+	tests := []struct {
+		from, to bigquery.FieldType
+		want     string
+		wantErr  bool
+	}{
+		{from: bigquery.IntegerFieldType, to: bigquery.TimestampFieldType, want: "TIMESTAMP_SECONDS(x)"},
+		{from: bigquery.TimestampFieldType, to: bigquery.IntegerFieldType, want: "UNIX_SECONDS(x)"},
+		{from: bigquery.IntegerFieldType, to: bigquery.StringFieldType, want: "CAST(x AS STRING)"},
+		{from: bigquery.StringFieldType, to: bigquery.BooleanFieldType, want: "CAST(x AS BOOL)"},
+		{from: bigquery.StringFieldType, to: bigquery.RecordFieldType, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := castExpr("x", tt.from, tt.to)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("castExpr(%s, %s) = %q, %v, want %q", tt.from, tt.to, got, err, tt.want)
+		}
+	}
+}
+
+func TestBigQueryMigrationsFromBaseline(t *testing.T) {
+	// the table the schema command created before there were migrations
+	live, err := migrationBaseline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pgp := fieldsByName(fieldsByName(fieldsByName(live)["rekord"].Schema)["signature"].Schema)["pgp"]; pgp == nil ||
+		pgp.Type != bigquery.StringFieldType || pgp.Repeated {
+		t.Fatalf("baseline Rekord.Signature.PGP = %+v, want a STRING", pgp)
+	}
+	plan, err := planMigrations(MigrationPlan{Dataset: "d", Table: "t", Exists: true}, bigQueryMigrations, live, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Steps) != len(bigQueryMigrations) {
+		t.Fatalf("plan has %d steps, want %d\n%s", len(plan.Steps), len(bigQueryMigrations), plan)
+	}
+	// apply the steps the way applyStep changes the table
+	for _, step := range plan.Steps {
+		added := mergeSchema(live, step.schema, false)
+		if step.rewrite() {
+			if _, err := rewriteQuery("`p.d.t`", added, step.schema); err != nil {
+				t.Fatalf("migration %d: %v", step.Version, err)
+			}
+			added = mergeSchema(added, step.schema, true)
+		}
+		live = added
+	}
+	if changes := diffSchemas("", live, entrySchema); len(changes) != 0 {
+		t.Errorf("schema after the migrations differs from entrySchema: %v", changes)
+	}
+	if _, err := planMigrations(MigrationPlan{Dataset: "d", Table: "t", Version: len(bigQueryMigrations), Exists: true},
+		bigQueryMigrations, live, true); err != nil {
+		t.Errorf("plan of the migrated table: %v", err)
+	}
+}
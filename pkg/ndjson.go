@@ -0,0 +1,241 @@
+package pkg
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// NDJSONOptions configures the rotating newline-delimited JSON sink.
+type NDJSONOptions struct {
+	// Dir is the directory the files and their manifests are written to.
+	Dir string
+	// Prefix is the prefix of the file names, defaults to phren.
+	Prefix string
+	// MaxEntries rotates the file after this many entries, 0 disables rotation by count.
+	MaxEntries int
+	// MaxBytes rotates the file after this many uncompressed bytes, 0 disables rotation by size.
+	MaxBytes int64
+	// Compression is one of "", "gzip" or "zstd".
+	Compression string
+}
+
+// NDJSONManifest describes a completed NDJSON file. It is written next to the file as <file>.manifest.json
+// once the file is closed, so a file without a manifest is incomplete.
+type NDJSONManifest struct {
+	File              string    `json:"file"`
+	Compression       string    `json:"compression,omitempty"`
+	Entries           int       `json:"entries"`
+	MinLogIndex       int       `json:"min_log_index"`
+	MaxLogIndex       int       `json:"max_log_index"`
+	Bytes             int64     `json:"bytes"`
+	UncompressedBytes int64     `json:"uncompressed_bytes"`
+	SHA256            string    `json:"sha256"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+type ndjsonSink struct {
+	opts    NDJSONOptions
+	mu      sync.Mutex
+	started string
+	seq     int
+	current *ndjsonFile
+}
+
+// ndjsonFile is the file currently being written.
+type ndjsonFile struct {
+	name     string
+	file     *os.File
+	hash     hash.Hash
+	counter  *countingWriter
+	compress io.WriteCloser
+	buf      *bufio.Writer
+	manifest NDJSONManifest
+}
+
+// NewNDJSONSink returns a Sink that appends the entries as newline-delimited JSON to files in opts.Dir,
+// rotated by entry count or size.
+func NewNDJSONSink(opts NDJSONOptions) (Sink, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("ndjson directory is required")
+	}
+	switch opts.Compression {
+	case "", "gzip", "zstd":
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", opts.Compression)
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "phren"
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("os.MkdirAll: %w", err)
+	}
+	return &ndjsonSink{
+		opts:    opts,
+		started: time.Now().UTC().Format("20060102T150405Z"),
+	}, nil
+}
+
+func (s *ndjsonSink) Write(entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(entry)
+}
+
+func (s *ndjsonSink) WriteBatch(entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range entries {
+		if err := s.write(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ndjsonSink) write(entry Entry) error {
+	if s.current == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	line, err := Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	line = append(line, '\n')
+	if _, err := s.current.buf.Write(line); err != nil {
+		return fmt.Errorf("File(%q).Write: %w", s.current.name, err)
+	}
+	m := &s.current.manifest
+	if m.Entries == 0 || entry.LogIndex < m.MinLogIndex {
+		m.MinLogIndex = entry.LogIndex
+	}
+	if m.Entries == 0 || entry.LogIndex > m.MaxLogIndex {
+		m.MaxLogIndex = entry.LogIndex
+	}
+	m.Entries++
+	m.UncompressedBytes += int64(len(line))
+	if (s.opts.MaxEntries > 0 && m.Entries >= s.opts.MaxEntries) ||
+		(s.opts.MaxBytes > 0 && m.UncompressedBytes >= s.opts.MaxBytes) {
+		return s.rotate()
+	}
+	return nil
+}
+
+// open starts the next file.
+func (s *ndjsonSink) open() error {
+	s.seq++
+	name := fmt.Sprintf("%s-%s-%06d.ndjson", s.opts.Prefix, s.started, s.seq)
+	switch s.opts.Compression {
+	case "gzip":
+		name += ".gz"
+	case "zstd":
+		name += ".zst"
+	}
+	file, err := os.Create(filepath.Join(s.opts.Dir, name))
+	if err != nil {
+		return fmt.Errorf("os.Create: %w", err)
+	}
+	f := &ndjsonFile{
+		name: name,
+		file: file,
+		hash: sha256.New(),
+		manifest: NDJSONManifest{
+			File:        name,
+			Compression: s.opts.Compression,
+		},
+	}
+	f.counter = &countingWriter{w: io.MultiWriter(file, f.hash)}
+	var w io.Writer = f.counter
+	switch s.opts.Compression {
+	case "gzip":
+		f.compress = gzip.NewWriter(f.counter)
+		w = f.compress
+	case "zstd":
+		enc, err := zstd.NewWriter(f.counter)
+		if err != nil {
+			//nolint
+			file.Close()
+			return fmt.Errorf("zstd.NewWriter: %w", err)
+		}
+		f.compress = enc
+		w = enc
+	}
+	f.buf = bufio.NewWriter(w)
+	s.current = f
+	return nil
+}
+
+// rotate closes the current file and writes its manifest.
+func (s *ndjsonSink) rotate() error {
+	f := s.current
+	if f == nil {
+		return nil
+	}
+	s.current = nil
+	if err := f.buf.Flush(); err != nil {
+		return fmt.Errorf("File(%q).Flush: %w", f.name, err)
+	}
+	if f.compress != nil {
+		if err := f.compress.Close(); err != nil {
+			return fmt.Errorf("File(%q).Close: %w", f.name, err)
+		}
+	}
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("File(%q).Close: %w", f.name, err)
+	}
+	f.manifest.Bytes = f.counter.n
+	f.manifest.SHA256 = hex.EncodeToString(f.hash.Sum(nil))
+	f.manifest.CreatedAt = time.Now().UTC()
+	manifest, err := Marshal(f.manifest)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	return os.WriteFile(filepath.Join(s.opts.Dir, f.name+".manifest.json"), manifest, 0o644) //nolint:gosec
+}
+
+// Flush pushes the buffered entries of the current file to disk without closing it.
+func (s *ndjsonSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		return nil
+	}
+	if err := s.current.buf.Flush(); err != nil {
+		return err
+	}
+	if f, ok := s.current.compress.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close closes the current file and writes its manifest.
+func (s *ndjsonSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rotate()
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNDJSONSinkRotation(t *testing.T) {
+	for _, compression := range []string{"", "gzip", "zstd"} {
+		t.Run(compression, func(t *testing.T) {
+			dir := t.TempDir()
+			sink, err := NewNDJSONSink(NDJSONOptions{Dir: dir, MaxEntries: 2, Compression: compression})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.WriteBatch([]Entry{{LogIndex: 1}, {LogIndex: 2}, {LogIndex: 3}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+			manifests, _ := filepath.Glob(filepath.Join(dir, "*.manifest.json"))
+			if len(manifests) != 2 {
+				t.Fatalf("got %d manifests, want 2", len(manifests))
+			}
+			total := 0
+			for _, path := range manifests {
+				b, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var m NDJSONManifest
+				if err := json.Unmarshal(b, &m); err != nil {
+					t.Fatal(err)
+				}
+				data, err := os.ReadFile(filepath.Join(dir, m.File))
+				if err != nil {
+					t.Fatal(err)
+				}
+				sum := sha256.Sum256(data)
+				if hex.EncodeToString(sum[:]) != m.SHA256 || int64(len(data)) != m.Bytes {
+					t.Errorf("manifest %s does not match the file", m.File)
+				}
+				if n := countLines(t, filepath.Join(dir, m.File), compression); n != m.Entries {
+					t.Errorf("file %s has %d lines, manifest says %d", m.File, n, m.Entries)
+				}
+				if m.MaxLogIndex-m.MinLogIndex != m.Entries-1 {
+					t.Errorf("manifest %s has range %d-%d for %d entries", m.File, m.MinLogIndex, m.MaxLogIndex, m.Entries)
+				}
+				total += m.Entries
+			}
+			if total != 3 {
+				t.Errorf("got %d entries, want 3", total)
+			}
+		})
+	}
+}
+
+func countLines(t *testing.T, path, compression string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	switch compression {
+	case "gzip":
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	case "zstd":
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	n := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	return n
+}
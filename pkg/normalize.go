@@ -0,0 +1,115 @@
+package pkg
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+)
+
+// entrySignature is the kind independent view of the data hash and signature of an entry.
+type entrySignature struct {
+	Format        string
+	PublicKey     string
+	Hash          RekorDataHash
+	PublicKeyInfo *PublicKeyInfo
+	X509          *X509
+	PGPKeys       []PGPKey
+	Keys          []SignatureKey
+}
+
+// signatureOf returns the signature of the rekord, hashedrekord or intoto entry.
+// It returns false for other kinds and for entries that could not be decoded.
+func signatureOf(e Entry) (entrySignature, bool) {
+	switch {
+	case e.Rekord != nil:
+		return entrySignature{
+			Format:        e.Rekord.Signature.Format,
+			PublicKey:     e.Rekord.Signature.PublicKey,
+			Hash:          e.Rekord.Data.Hash,
+			PublicKeyInfo: e.Rekord.Signature.PublicKeyInfo,
+			X509:          e.Rekord.Signature.X509,
+			PGPKeys:       e.Rekord.Signature.PGPKeys,
+			Keys:          e.Rekord.Signature.Keys,
+		}, true
+	case e.HashedRekord != nil:
+		return entrySignature{
+			Format:        "x509",
+			PublicKey:     e.HashedRekord.Signature.PublicKey,
+			Hash:          e.HashedRekord.Data.Hash,
+			PublicKeyInfo: e.HashedRekord.Signature.PublicKeyInfo,
+			X509:          e.HashedRekord.Signature.X509,
+		}, true
+	case e.Intoto != nil:
+		return entrySignature{
+			Format:        "x509",
+			PublicKey:     e.Intoto.Signature.PublicKey,
+			Hash:          e.Intoto.Data.Hash,
+			PublicKeyInfo: e.Intoto.Signature.PublicKeyInfo,
+			X509:          e.Intoto.Signature.X509,
+		}, true
+	}
+	return entrySignature{}, false
+}
+
+// issuer returns the certificate issuer of the entry, preferring the organization over the common name.
+func (s entrySignature) issuer() string {
+	if s.X509 == nil {
+		return ""
+	}
+	if s.X509.IssuerOrganization != "" {
+		return s.X509.IssuerOrganization
+	}
+	return s.X509.IssuerCommonName
+}
+
+// fingerprint returns the fingerprint of the signing key.
+func (s entrySignature) fingerprint() string {
+	if s.PublicKeyInfo != nil {
+		return s.PublicKeyInfo.FingerprintSHA256
+	}
+	if len(s.Keys) > 0 {
+		return s.Keys[0].Fingerprint
+	}
+	return ""
+}
+
+// identities returns the identities the signature claims: certificate subject alternative names,
+// PGP user IDs and the identities reported by the signature format parsers.
+func (s entrySignature) identities() []string {
+	seen := map[string]bool{}
+	var result []string
+	add := func(ids ...string) {
+		for _, id := range ids {
+			if id != "" && !seen[id] {
+				seen[id] = true
+				result = append(result, id)
+			}
+		}
+	}
+	for _, k := range s.Keys {
+		add(k.Identities...)
+	}
+	for _, k := range s.PGPKeys {
+		for _, uid := range k.UIDs {
+			add(uid.Email, uid.Name)
+		}
+	}
+	add(s.subjectAlternativeNames()...)
+	return result
+}
+
+// subjectAlternativeNames returns the email, URI and DNS subject alternative names of the signing
+// certificate, or its common name when it has none.
+func (s entrySignature) subjectAlternativeNames() []string {
+	if s.X509 == nil {
+		return nil
+	}
+	block, _ := pem.Decode([]byte(s.PublicKey))
+	if block == nil {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+	return certificateKey("", cert).Identities
+}
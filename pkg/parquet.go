@@ -0,0 +1,203 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// entryParquetSchema is derived from Entry by parquet.SchemaOf. Entry has no parquet tags, so the columns are
+// named after the Go fields, nested structs become groups and slices become repeated fields.
+var entryParquetSchema = parquet.SchemaOf(Entry{})
+
+// ParquetOptions configures the Parquet sink.
+type ParquetOptions struct {
+	// Dir is the root directory, files are written to <Dir>/integrated_date=YYYY-MM-DD/.
+	Dir string
+	// Prefix is the prefix of the file names, defaults to phren.
+	Prefix string
+	// RowGroupSize is the number of entries in a row group, defaults to 10000.
+	RowGroupSize int
+	// MaxOpenFiles is the number of dates whose files are kept open, defaults to 16. The least recently written
+	// file is closed to open another one, a date written again later gets a new file.
+	MaxOpenFiles int
+}
+
+type parquetSink struct {
+	opts       ParquetOptions
+	mu         sync.Mutex
+	started    string
+	partitions map[string]*parquetPartition
+	// files is the number of files opened for every date.
+	files map[string]int
+	// writes orders the partitions by their last write.
+	writes uint64
+}
+
+// parquetPartition is the open file of a single integrated date.
+type parquetPartition struct {
+	tmp       string
+	name      string
+	file      *os.File
+	writer    *parquet.GenericWriter[Entry]
+	rows      int
+	lastWrite uint64
+}
+
+// NewParquetSink returns a Sink that writes the entries as Parquet files partitioned by integrated date.
+// The files are written under a temporary name and renamed into place when they are closed.
+func NewParquetSink(opts ParquetOptions) (Sink, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("parquet directory is required")
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "phren"
+	}
+	if opts.RowGroupSize <= 0 {
+		opts.RowGroupSize = 10000
+	}
+	if opts.MaxOpenFiles <= 0 {
+		opts.MaxOpenFiles = 16
+	}
+	return &parquetSink{
+		opts:       opts,
+		started:    time.Now().UTC().Format("20060102T150405Z"),
+		partitions: map[string]*parquetPartition{},
+		files:      map[string]int{},
+	}, nil
+}
+
+func (s *parquetSink) Write(entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(entry)
+}
+
+func (s *parquetSink) WriteBatch(entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range entries {
+		if err := s.write(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *parquetSink) write(entry Entry) error {
+	date := time.Unix(int64(entry.IntegratedTime), 0).UTC().Format("2006-01-02")
+	p, ok := s.partitions[date]
+	if !ok {
+		if len(s.partitions) >= s.opts.MaxOpenFiles {
+			if err := s.closeLeastRecent(); err != nil {
+				return err
+			}
+		}
+		var err error
+		p, err = s.open(date)
+		if err != nil {
+			return err
+		}
+		s.partitions[date] = p
+	}
+	s.writes++
+	p.lastWrite = s.writes
+	if _, err := p.writer.Write([]Entry{entry}); err != nil {
+		return fmt.Errorf("File(%q).Write: %w", p.name, err)
+	}
+	p.rows++
+	if p.rows >= s.opts.RowGroupSize {
+		p.rows = 0
+		if err := p.writer.Flush(); err != nil {
+			return fmt.Errorf("File(%q).Flush: %w", p.name, err)
+		}
+	}
+	return nil
+}
+
+// open creates the file of the given integrated date.
+func (s *parquetSink) open(date string) (*parquetPartition, error) {
+	dir := filepath.Join(s.opts.Dir, "integrated_date="+date)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("os.MkdirAll: %w", err)
+	}
+	name := filepath.Join(dir, fmt.Sprintf("%s-%s-%06d.parquet", s.opts.Prefix, s.started, s.files[date]))
+	s.files[date]++
+	file, err := os.CreateTemp(dir, ".parquet-*")
+	if err != nil {
+		return nil, fmt.Errorf("os.CreateTemp: %w", err)
+	}
+	return &parquetPartition{
+		tmp:    file.Name(),
+		name:   name,
+		file:   file,
+		writer: parquet.NewGenericWriter[Entry](file, entryParquetSchema),
+	}, nil
+}
+
+// Flush ends the current row group of every open file.
+func (s *parquetSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.partitions {
+		if p.rows == 0 {
+			continue
+		}
+		p.rows = 0
+		if err := p.writer.Flush(); err != nil {
+			return fmt.Errorf("File(%q).Flush: %w", p.name, err)
+		}
+	}
+	return nil
+}
+
+// closeLeastRecent closes the file that was written to least recently.
+func (s *parquetSink) closeLeastRecent() error {
+	var oldest string
+	for date, p := range s.partitions {
+		if oldest == "" || p.lastWrite < s.partitions[oldest].lastWrite {
+			oldest = date
+		}
+	}
+	p := s.partitions[oldest]
+	delete(s.partitions, oldest)
+	return p.close()
+}
+
+// Close writes the footer of every open file and renames it into place.
+func (s *parquetSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for date, p := range s.partitions {
+		delete(s.partitions, date)
+		if err := p.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// close writes the footer of the file and renames it into place.
+func (p *parquetPartition) close() error {
+	if err := p.writer.Close(); err != nil {
+		//nolint
+		p.file.Close()
+		return fmt.Errorf("File(%q).Close: %w", p.name, err)
+	}
+	if err := p.file.Close(); err != nil {
+		return fmt.Errorf("File(%q).Close: %w", p.name, err)
+	}
+	if err := os.Chmod(p.tmp, 0o644); err != nil { //nolint:gosec
+		return fmt.Errorf("os.Chmod: %w", err)
+	}
+	if err := os.Rename(p.tmp, p.name); err != nil {
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+	return nil
+}
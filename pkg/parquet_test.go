@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParquetSinkPartitions(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewParquetSink(ParquetOptions{Dir: dir, RowGroupSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{LogIndex: 1, IntegratedTime: int(day.Unix()), Kind: Kind{Kind: "rekord"}, Rekord: &Rekord{
+			Signature: Signature{PGPKeys: []PGPKey{{UIDs: []PGPUID{{Email: "jane@example.com"}}}}},
+		}},
+		{LogIndex: 2, IntegratedTime: int(day.Unix()), HashedRekord: &Hashedrekord{}},
+		{LogIndex: 3, IntegratedTime: int(day.Unix()), Intoto: &InToTo{}},
+		{LogIndex: 4, IntegratedTime: int(day.AddDate(0, 0, 1).Unix())},
+	}
+	if err := sink.WriteBatch(entries); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "integrated_date=2022-10-01", "*.parquet"))
+	if len(files) != 1 {
+		t.Fatalf("got %d files for 2022-10-01, want 1", len(files))
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stat, _ := f.Stat()
+	pf, err := parquet.OpenFile(f, stat.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pf.RowGroups()) != 2 {
+		t.Errorf("got %d row groups, want 2", len(pf.RowGroups()))
+	}
+	rows, err := parquet.Read[Entry](f, stat.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0].Rekord.Signature.PGPKeys[0].UIDs[0].Email != "jane@example.com" {
+		t.Errorf("got rows %+v", rows)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "integrated_date=2022-10-02", "*.parquet")); len(files) != 1 {
+		t.Errorf("got %d files for 2022-10-02, want 1", len(files))
+	}
+}
+
+func TestParquetSinkMaxOpenFiles(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewParquetSink(ParquetOptions{Dir: dir, MaxOpenFiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	// the third date closes the first one, which gets a second file when it is written again
+	for i, days := range []int{0, 1, 2, 0} {
+		if err := sink.Write(Entry{LogIndex: i, IntegratedTime: int(day.AddDate(0, 0, days).Unix())}); err != nil {
+			t.Fatal(err)
+		}
+		if open := len(sink.(*parquetSink).partitions); open > 2 {
+			t.Fatalf("%d files open, want at most 2", open)
+		}
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "integrated_date=2022-10-01", "*.parquet")); len(files) != 1 {
+		t.Errorf("got %d closed files for 2022-10-01 before Close, want 1", len(files))
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for date, want := range map[string]int{"2022-10-01": 2, "2022-10-02": 1, "2022-10-03": 1} {
+		files, _ := filepath.Glob(filepath.Join(dir, "integrated_date="+date, "*.parquet"))
+		if len(files) != want {
+			t.Errorf("got %d files for %s, want %d", len(files), date, want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	//nolint
+	"golang.org/x/crypto/openpgp"
+	//nolint
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// getPGPKeys returns the structured metadata of every key in the given key ring.
+// Both armored and binary key rings are accepted.
+func getPGPKeys(p []byte) ([]PGPKey, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(p))
+	if err != nil {
+		var binErr error
+		entities, binErr = openpgp.ReadKeyRing(bytes.NewReader(p))
+		if binErr != nil {
+			return nil, fmt.Errorf("unable to read key ring: armored: %v, binary: %v", err, binErr)
+		}
+	}
+	keys := make([]PGPKey, 0, len(entities))
+	for _, entity := range entities {
+		keys = append(keys, pgpKey(entity))
+	}
+	return keys, nil
+}
+
+// pgpIdentities returns the user IDs of the keys concatenated without a separator, the value the pgp column
+// has always had.
+func pgpIdentities(keys []PGPKey) string {
+	var b strings.Builder
+	for _, k := range keys {
+		for _, uid := range k.UIDs {
+			b.WriteString(pgpUIDString(uid))
+		}
+	}
+	return b.String()
+}
+
+// pgpUIDString formats the user ID the way OpenPGP does, "Name (Comment) <email>".
+func pgpUIDString(uid PGPUID) string {
+	var parts []string
+	if uid.Name != "" {
+		parts = append(parts, uid.Name)
+	}
+	if uid.Comment != "" {
+		parts = append(parts, "("+uid.Comment+")")
+	}
+	if uid.Email != "" {
+		parts = append(parts, "<"+uid.Email+">")
+	}
+	return strings.Join(parts, " ")
+}
+
+// pgpKey converts an openpgp entity into a PGPKey.
+func pgpKey(entity *openpgp.Entity) PGPKey {
+	pk := entity.PrimaryKey
+	key := PGPKey{
+		Fingerprint:  strings.ToUpper(fmt.Sprintf("%x", pk.Fingerprint)),
+		KeyID:        pk.KeyIdString(),
+		Algorithm:    pgpAlgorithm(pk.PubKeyAlgo),
+		CreationTime: pk.CreationTime,
+	}
+	if bits, err := pk.BitLength(); err == nil {
+		key.Bits = int(bits)
+	}
+
+	// identities are stored in a map, sort them so the output is stable.
+	names := make([]string, 0, len(entity.Identities))
+	for name := range entity.Identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		identity := entity.Identities[name]
+		if identity.UserId != nil {
+			key.UIDs = append(key.UIDs, PGPUID{
+				Name:    identity.UserId.Name,
+				Comment: identity.UserId.Comment,
+				Email:   identity.UserId.Email,
+			})
+		} else {
+			key.UIDs = append(key.UIDs, PGPUID{Name: name})
+		}
+	}
+	if expiry, ok := pgpExpiration(pk.CreationTime, pgpSelfSignature(entity)); ok {
+		key.ExpirationTime = expiry
+	}
+
+	for _, sub := range entity.Subkeys {
+		subkey := PGPSubkey{
+			Fingerprint:  strings.ToUpper(fmt.Sprintf("%x", sub.PublicKey.Fingerprint)),
+			KeyID:        sub.PublicKey.KeyIdString(),
+			Algorithm:    pgpAlgorithm(sub.PublicKey.PubKeyAlgo),
+			CreationTime: sub.PublicKey.CreationTime,
+		}
+		if bits, err := sub.PublicKey.BitLength(); err == nil {
+			subkey.Bits = int(bits)
+		}
+		if expiry, ok := pgpExpiration(sub.PublicKey.CreationTime, sub.Sig); ok {
+			subkey.ExpirationTime = expiry
+		}
+		key.Subkeys = append(key.Subkeys, subkey)
+	}
+	return key
+}
+
+// pgpSelfSignature returns the self-signature that defines the expiry of the primary key: the one of the primary
+// identity, or the latest one when no identity, or more than one, is marked as primary.
+func pgpSelfSignature(entity *openpgp.Entity) *packet.Signature {
+	var selected *packet.Signature
+	for _, identity := range entity.Identities {
+		sig := identity.SelfSignature
+		if sig == nil {
+			continue
+		}
+		if selected == nil {
+			selected = sig
+			continue
+		}
+		primary, selectedPrimary := sig.IsPrimaryId != nil && *sig.IsPrimaryId,
+			selected.IsPrimaryId != nil && *selected.IsPrimaryId
+		if primary != selectedPrimary {
+			if primary {
+				selected = sig
+			}
+			continue
+		}
+		if sig.CreationTime.After(selected.CreationTime) {
+			selected = sig
+		}
+	}
+	return selected
+}
+
+// pgpExpiration returns the expiry of a key from its binding signature, if it has one.
+func pgpExpiration(created time.Time, sig *packet.Signature) (time.Time, bool) {
+	if sig == nil || sig.KeyLifetimeSecs == nil || *sig.KeyLifetimeSecs == 0 {
+		return time.Time{}, false
+	}
+	return created.Add(time.Duration(*sig.KeyLifetimeSecs) * time.Second), true
+}
+
+// pubKeyAlgoEdDSA is the EdDSA algorithm of RFC 4880bis, x/crypto/openpgp predates it and has no constant for it.
+const pubKeyAlgoEdDSA packet.PublicKeyAlgorithm = 22
+
+// pgpAlgorithm returns a readable name for the public key algorithm.
+func pgpAlgorithm(algo packet.PublicKeyAlgorithm) string {
+	switch algo {
+	case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSAEncryptOnly, packet.PubKeyAlgoRSASignOnly:
+		return "RSA"
+	case packet.PubKeyAlgoElGamal:
+		return "ElGamal"
+	case packet.PubKeyAlgoDSA:
+		return "DSA"
+	case packet.PubKeyAlgoECDH:
+		return "ECDH"
+	case packet.PubKeyAlgoECDSA:
+		return "ECDSA"
+	case pubKeyAlgoEdDSA:
+		return "EdDSA"
+	default:
+		return fmt.Sprintf("unknown(%d)", algo)
+	}
+}
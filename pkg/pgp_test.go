@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	//nolint
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// testPGPKey is an RSA key created on 2023-01-02 with two identities: the primary "Alice Example" expires the key
+// after a year, "Zed Example" sorts last and claims ten years.
+const testPGPKey = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+xsBNBGOySaUBCADXWCRjzhYO0OR2PcKqEnJ+PAobbTKlkOBPxn1mq618bkxFfDmI
+0ADBpzWbGVsO3OnikxSUoGEFfw8xNrZSRT6bWPvRs8AXB+RnQ/4HC570QhalwRpH
+6SNwSrb1Bh1HltdP+c98U7CDHbZPixhZgMflv9SvnyGIsczp+oKSHFtVE0UiThdu
+cTMbybpCy7GY0CwN9rB6BEF4cWWFM4lUOJY9PftabXBtYwCSe4kUt8yVbyEv5gjr
+0rOo0snoTVeeLhPguXqqcFqh5UZKDCWk+Ar/U3en8JWNJHVVWRhSYJhS0Uou0I/b
+8lF+lXhXzn/06BtTrTMhumaD1A25LFmXB4ehABEBAAHNIUFsaWNlIEV4YW1wbGUg
+PGFsaWNlQGV4YW1wbGUuY29tPsLAawQTAQgAHwUCY7JJpQkQhJRfLA+XCCsCGwMF
+CQHhM4ACGQECFQgAAP3qCAAq26R6Q1PC8Av6FA1XDLzFd3GlmSeI+m/CcAWBbEt1
+xRbkzkXxRcbRd9NhFx2ZnffRX6K/2lLF6MGNp+iSU2miSk/98JubYXp5/PYWF33e
+7/Z02dquzWoIfA7JO3UaQmBdYpqU1k5ZsbElQAQ1IGWn0577Y28BPh/N6VFzLIs8
+jv9S213Rw0PmRaH2iigosmFZRkHfRyMp8FjaanGejzxjAUbfJ6v5UAHsafXRBPtE
+rEA3ZQZYxuaFuHS/LSHwoiloFnDB1+g0FYE6CJAgslxBgqByXOueRPmsr6cLFRN3
+IR6kjAcv9cr5MuiBTuMTJGwRAw4Jn8IYgry1drAN6HsvzSRaZWQgRXhhbXBsZSAo
+d29yaykgPHplZEBleGFtcGxlLmNvbT7CwGUEEwEIABkFAmOyV7UJEISUXywPlwgr
+AhsDBQkSzAMAAAB5hQgACdGMvgpE5Z/BzGQPfFfAUult3KNKa9T0ChPOtNYtlwAW
+mbC2rD33/wFF2BNuleDfZ26rFTsgZ2xdJvYqdrKfqhqqsrxRsdHWOte9S1sfkj3U
+VK59QoTx8l28AsuBOGIbwrwmlqEB9xk2Yaz1chuwylZ3m8oIDerh73IPnt+4brc2
++c5lVjBNb86HOxKrl/l5imKR2NvjyukVdSPDbP9XfxXpE7MnJVNyA+J2b+ImpywN
+mrT/Ep5VCBbLTyTCiS2sJ8yjX5uqVlEvxsZXfMYmrKTa6aDma/yeWVvRGQUJU3hr
+s3Y+hvr6IUzC92LRy6o+RlaqkhwX5A/J4jjnqpcJIM7ATQRjskmlAQgAkrspG81T
+UwMEOyE4MIB0iMzJzvud6pQltL1Bgzln+ztZZ2kliy2XozZD/RNF/hTs8cm9oOrQ
+/aZt68wwOHgEFWLK7bxqCwpchXo8bX8bLIYAGXWYhqtwKY3wil0DBx2EyYRr+uNE
+Q2NAPmfZbFa5YtL83DfxdBTFCKxDWoKcyw3INKwn8Zcd33dcHOkgrqmZcIKAZOe6
+CP3JLomX5WHVaJaEtn1PkhWkp71/Mvb7xWuICyt3vkMO0O31otwMZV6DD+rsYQuA
+RbWBwU++ruFdQWqUK8+GUzUJ+7p0scxle23S6mhxv1Q+whGHUBH4OW2RBRkUnKt3
+1sfLxktk2ogtGQARAQABwsBfBBgBCAATBQJjskmlCRCElF8sD5cIKwIbDAAAHG4I
+AAicAEZcWRqbIH1zjbf6GsitNLPB92OG6nGzmvreXRDhe73naVjIabh342sqaWGX
+6jq04yCm4bxnKxPgeKwIt0XnCaThrPK0j2vFMH+WULF3+HXx2kvKt3XYnr3lMI3d
+6wAiPBXBME1cJm2CCCSJ9b9X3oWynOVpHZ8TAY1KBbMKcqrihVHZ8sBT9u6GzAiM
+lbjUu7/sDNR4qCSX0EE89mfic76R9t3r0QEi+aK+4hCtymOLO1Iqujio9vvAJN2s
+doaVgLTFQ5UG6roCMhSI4TQmSXwum8MWiFdQ75a5ct60VsPr74vpDcPBFSLyysXN
+VB1NYsUsi0aFGO7EVjl2vP4=
+=kXtm
+-----END PGP PUBLIC KEY BLOCK-----`
+
+func TestGetPGPKeys(t *testing.T) {
+	keys, err := getPGPKeys([]byte(testPGPKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("got %d keys, want 1", len(keys))
+	}
+	key := keys[0]
+	created := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	if key.Algorithm != "RSA" || key.Bits != 2048 || !key.CreationTime.Equal(created) {
+		t.Errorf("got %s %d created %v", key.Algorithm, key.Bits, key.CreationTime)
+	}
+	if len(key.Fingerprint) != 40 || key.KeyID != key.Fingerprint[24:] {
+		t.Errorf("got fingerprint %s, key id %s", key.Fingerprint, key.KeyID)
+	}
+	// the expiry comes from the primary identity, not the identity that sorts last
+	if want := created.Add(365 * 24 * time.Hour); !key.ExpirationTime.Equal(want) {
+		t.Errorf("got expiration %v, want %v", key.ExpirationTime, want)
+	}
+	wantUIDs := []PGPUID{
+		{Name: "Alice Example", Email: "alice@example.com"},
+		{Name: "Zed Example", Comment: "work", Email: "zed@example.com"},
+	}
+	if !reflect.DeepEqual(key.UIDs, wantUIDs) {
+		t.Errorf("got uids %+v, want %+v", key.UIDs, wantUIDs)
+	}
+	if len(key.Subkeys) != 1 || key.Subkeys[0].Algorithm != "RSA" || key.Subkeys[0].Bits != 2048 {
+		t.Errorf("got subkeys %+v", key.Subkeys)
+	}
+}
+
+func TestGetPGPKeysInvalid(t *testing.T) {
+	if _, err := getPGPKeys([]byte("not a key")); err == nil {
+		t.Error("getPGPKeys() error = nil, want an error")
+	}
+}
+
+func TestPGPAlgorithm(t *testing.T) {
+	tests := []struct {
+		algo packet.PublicKeyAlgorithm
+		want string
+	}{
+		{algo: packet.PubKeyAlgoRSA, want: "RSA"},
+		{algo: packet.PubKeyAlgoDSA, want: "DSA"},
+		{algo: packet.PubKeyAlgoECDSA, want: "ECDSA"},
+		{algo: pubKeyAlgoEdDSA, want: "EdDSA"},
+		{algo: 99, want: "unknown(99)"},
+	}
+	for _, tt := range tests {
+		if got := pgpAlgorithm(tt.algo); got != tt.want {
+			t.Errorf("pgpAlgorithm(%d) = %s, want %s", tt.algo, got, tt.want)
+		}
+	}
+}
+
+func TestParsePGPSignature(t *testing.T) {
+	var s Signature
+	if err := parsePGPSignature([]byte(testPGPKey), &s); err != nil {
+		t.Fatal(err)
+	}
+	// the pgp column keeps the user IDs the way it always had them, the keys are in their own column
+	if want := "Alice Example <alice@example.com>Zed Example (work) <zed@example.com>"; s.PGP != want {
+		t.Errorf("PGP = %q, want %q", s.PGP, want)
+	}
+	if len(s.PGPKeys) != 1 || len(s.PGPKeys[0].UIDs) != 2 {
+		t.Errorf("PGPKeys = %+v, want one key with two user IDs", s.PGPKeys)
+	}
+}
@@ -0,0 +1,58 @@
+package pkg
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+)
+
+// getPublicKeyInfo returns the algorithm, size and SPKI fingerprint of the given PEM encoded
+// public key. Both bare PKIX keys and certificates are accepted.
+func getPublicKeyInfo(publicKey string) (*PublicKeyInfo, error) {
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil {
+		return nil, fmt.Errorf("failed to parse PEM block containing the key")
+	}
+	var spki []byte
+	var key interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		spki = cert.RawSubjectPublicKeyInfo
+		key = cert.PublicKey
+	default:
+		k, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		spki = block.Bytes
+		key = k
+	}
+	sum := sha256.Sum256(spki)
+	info := PublicKeyInfo{
+		FingerprintSHA256: hex.EncodeToString(sum[:]),
+	}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		info.Algorithm = "RSA"
+		info.Bits = k.N.BitLen()
+	case *ecdsa.PublicKey:
+		info.Algorithm = "ECDSA"
+		info.Curve = k.Curve.Params().Name
+		info.Bits = k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		info.Algorithm = "Ed25519"
+		info.Bits = len(k) * 8
+	default:
+		info.Algorithm = fmt.Sprintf("%T", k)
+	}
+	return &info, nil
+}
@@ -0,0 +1,64 @@
+package pkg
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func TestGetPublicKeyInfo(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		key       interface{}
+		algorithm string
+		curve     string
+		bits      int
+	}{
+		{name: "ecdsa", key: &ecKey.PublicKey, algorithm: "ECDSA", curve: "P-256", bits: 256},
+		{name: "rsa", key: &rsaKey.PublicKey, algorithm: "RSA", bits: 2048},
+		{name: "ed25519", key: edKey, algorithm: "Ed25519", bits: 256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der, err := x509.MarshalPKIXPublicKey(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+			got, err := getPublicKeyInfo(p)
+			if err != nil {
+				t.Fatalf("getPublicKeyInfo() error = %v", err)
+			}
+			if got.Algorithm != tt.algorithm || got.Curve != tt.curve || got.Bits != tt.bits {
+				t.Errorf("getPublicKeyInfo() got = %+v", got)
+			}
+			if len(got.FingerprintSHA256) != 64 {
+				t.Errorf("getPublicKeyInfo() fingerprint = %q", got.FingerprintSHA256)
+			}
+			again, _ := getPublicKeyInfo(p)
+			if again.FingerprintSHA256 != got.FingerprintSHA256 {
+				t.Errorf("getPublicKeyInfo() fingerprint is not stable")
+			}
+		})
+	}
+	if _, err := getPublicKeyInfo("not a key"); err == nil {
+		t.Errorf("getPublicKeyInfo() expected error for invalid input")
+	}
+}
@@ -0,0 +1,152 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Options configures a bucket on an S3-compatible object storage such as AWS S3, MinIO or Cloudflare R2.
+type S3Options struct {
+	// Bucket is the name of the bucket.
+	Bucket string
+	// Endpoint is the host, or URL, of the service, defaults to s3.amazonaws.com.
+	// A http:// URL disables TLS, e.g. http://localhost:9000 for a local MinIO.
+	Endpoint string
+	// Region is the region of the bucket, e.g. us-east-1 or auto for R2.
+	Region string
+	// AccessKeyID, SecretAccessKey and SessionToken are static credentials. When they are not set the
+	// credentials are taken from the AWS environment variables, the shared credentials file or the instance role.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// PathStyle addresses the bucket as <endpoint>/<bucket> instead of <bucket>.<endpoint>, MinIO needs it.
+	PathStyle bool
+}
+
+// s3ChecksumKey is the user metadata key holding the hex encoded SHA-256 of the object.
+const s3ChecksumKey = "Sha256"
+
+type s3Bucket struct {
+	client *minio.Client
+	name   string
+}
+
+// NewS3Bucket returns a Bucket that writes the same <logIndex>/entry.json and <logIndex>/raw.json layout as
+// the GCS bucket to an S3-compatible object storage.
+func NewS3Bucket(opts S3Options) (Bucket, error) {
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket name is required")
+	}
+	endpoint, secure, err := s3Endpoint(opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{},
+	})
+	if opts.AccessKeyID != "" || opts.SecretAccessKey != "" {
+		creds = credentials.NewStaticV4(opts.AccessKeyID, opts.SecretAccessKey, opts.SessionToken)
+	}
+	lookup := minio.BucketLookupAuto
+	if opts.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:        creds,
+		Secure:       secure,
+		Region:       opts.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("minio.New: %w", err)
+	}
+	return &s3Bucket{client: client, name: opts.Bucket}, nil
+}
+
+// s3Endpoint splits the endpoint into the host and whether TLS is used.
+func s3Endpoint(endpoint string) (string, bool, error) {
+	if endpoint == "" {
+		return "s3.amazonaws.com", true, nil
+	}
+	if !strings.Contains(endpoint, "://") {
+		return endpoint, true, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid s3 endpoint %q %w", endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false, fmt.Errorf("invalid s3 endpoint scheme %q", u.Scheme)
+	}
+	return u.Host, u.Scheme == "https", nil
+}
+
+func (b s3Bucket) UpdateBucket(item Entry) error {
+	json, err := Marshal(item)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	return b.write(fmt.Sprintf("%d/entry.json", item.LogIndex), json)
+}
+
+// UpdateBucketRaw writes the raw rekor response to <index>/raw.json so the archive can be re-decoded later.
+func (b s3Bucket) UpdateBucketRaw(index int64, raw []byte) error {
+	return b.write(fmt.Sprintf("%d/raw.json", index), raw)
+}
+
+// RawEntry reads <index>/raw.json from the bucket and verifies it against the stored checksum.
+func (b s3Bucket) RawEntry(index int64) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	path := fmt.Sprintf("%d/raw.json", index)
+	obj, err := b.client.GetObject(ctx, b.name, path, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("GetObject(%q): %w", path, err)
+	}
+	//nolint
+	defer obj.Close()
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, fmt.Errorf("GetObject(%q).Read: %w", path, err)
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("GetObject(%q).Stat: %w", path, err)
+	}
+	if want := info.UserMetadata[s3ChecksumKey]; want != "" {
+		sum := sha256.Sum256(data)
+		if got := hex.EncodeToString(sum[:]); got != want {
+			return nil, fmt.Errorf("object %q has sha256 %s, want %s", path, got, want)
+		}
+	}
+	return data, nil
+}
+
+// write uploads the object with its content type. The server verifies the Content-MD5 of the upload and
+// the SHA-256 is kept in the object metadata so readers can verify what they download.
+func (b s3Bucket) write(path string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	sum := sha256.Sum256(data)
+	_, err := b.client.PutObject(ctx, b.name, path, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType:    "application/json",
+		SendContentMd5: true,
+		UserMetadata:   map[string]string{s3ChecksumKey: hex.EncodeToString(sum[:])},
+	})
+	if err != nil {
+		return fmt.Errorf("PutObject(%q): %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestS3Endpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		host     string
+		secure   bool
+		wantErr  bool
+	}{
+		{name: "default", endpoint: "", host: "s3.amazonaws.com", secure: true},
+		{name: "host", endpoint: "abc.r2.cloudflarestorage.com", host: "abc.r2.cloudflarestorage.com", secure: true},
+		{name: "http", endpoint: "http://localhost:9000", host: "localhost:9000", secure: false},
+		{name: "https", endpoint: "https://s3.eu-west-1.amazonaws.com", host: "s3.eu-west-1.amazonaws.com", secure: true},
+		{name: "scheme", endpoint: "ftp://localhost", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, secure, err := s3Endpoint(tt.endpoint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("s3Endpoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if host != tt.host || secure != tt.secure {
+				t.Errorf("s3Endpoint() = %q, %v, want %q, %v", host, secure, tt.host, tt.secure)
+			}
+		})
+	}
+}
+
+// TestS3Bucket needs an S3-compatible server, e.g.
+//
+//	docker run -d -p 9000:9000 minio/minio server /data
+//	PHREN_TEST_S3_ENDPOINT=http://localhost:9000 AWS_ACCESS_KEY_ID=minioadmin AWS_SECRET_ACCESS_KEY=minioadmin \
+//		go test ./pkg -run S3
+func TestS3Bucket(t *testing.T) {
+	endpoint := os.Getenv("PHREN_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("PHREN_TEST_S3_ENDPOINT is not set")
+	}
+	name := fmt.Sprintf("phren-test-%d", time.Now().UnixNano())
+	b, err := NewS3Bucket(S3Options{Bucket: name, Endpoint: endpoint, Region: "us-east-1", PathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := b.(*s3Bucket).client
+	ctx := context.Background()
+	if err := client.MakeBucket(ctx, name, minio.MakeBucketOptions{Region: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for obj := range client.ListObjects(ctx, name, minio.ListObjectsOptions{Recursive: true}) {
+			//nolint
+			client.RemoveObject(ctx, name, obj.Key, minio.RemoveObjectOptions{})
+		}
+		//nolint
+		client.RemoveBucket(ctx, name)
+	})
+
+	if err := b.UpdateBucket(Entry{LogIndex: 42, LogID: "c0d23d6a"}); err != nil {
+		t.Fatal(err)
+	}
+	raw := []byte(`{"42":{"body":"e30="}}`)
+	if err := b.UpdateBucketRaw(42, raw); err != nil {
+		t.Fatal(err)
+	}
+	got, err := b.RawEntry(42)
+	if err != nil || string(got) != string(raw) {
+		t.Errorf("RawEntry() = %s, %v, want %s", got, err, raw)
+	}
+	info, err := client.StatObject(ctx, name, "42/entry.json", minio.StatObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ContentType != "application/json" || info.UserMetadata[s3ChecksumKey] == "" {
+		t.Errorf("entry.json content type %q, metadata %v", info.ContentType, info.UserMetadata)
+	}
+	if _, err := b.RawEntry(43); err == nil {
+		t.Error("RawEntry() of a missing object succeeded")
+	}
+}
@@ -0,0 +1,214 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// EntryBigQuerySchema returns the schema of the BigQuery table in the JSON format of `bq mk --schema`.
+func EntryBigQuerySchema() ([]byte, error) {
+	data, err := entrySchema.ToJSONFields()
+	if err != nil {
+		return nil, fmt.Errorf("Schema.ToJSONFields: %w", err)
+	}
+	return data, nil
+}
+
+// DiffEntrySchema returns the changes between the schema of a table, or a schema file, and the schema the rows
+// are saved with. A column the rows do not have anymore is a breaking ColumnRemoved, even though migrations
+// keep it in the table.
+func DiffEntrySchema(schema bigquery.Schema) []ColumnChange {
+	changes := diffSchemas("", schema, entrySchema)
+	for i := range changes {
+		if changes[i].Kind == ColumnKept {
+			changes[i].Kind = ColumnRemoved
+		}
+	}
+	return changes
+}
+
+// TableSchema returns the schema of the table.
+func (b *BigQuery) TableSchema(dataset, table string) (bigquery.Schema, error) {
+	if dataset == "" {
+		return nil, fmt.Errorf("dataset is required")
+	}
+	if table == "" {
+		return nil, fmt.Errorf("table is required")
+	}
+	md, err := b.client.Dataset(dataset).Table(table).Metadata(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("Table.Metadata: %w", err)
+	}
+	return md.Schema, nil
+}
+
+// jsonSchemaID is the $id of the exported JSON Schema.
+const jsonSchemaID = "https://github.com/naveensrinivasan/rekor-phren/schema/entry.json"
+
+// EntryJSONSchema returns the JSON Schema of the entries as they are written to the buckets and the NDJSON,
+// filesystem, Kafka, NATS and webhook sinks. The structs are defined once in $defs.
+func EntryJSONSchema() ([]byte, error) {
+	defs := map[string]interface{}{}
+	root := jsonSchemaType(reflect.TypeOf(Entry{}), defs)
+	schema := map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     jsonSchemaID,
+		"title":   "rekor-phren entry",
+		"$ref":    root["$ref"],
+		"$defs":   defs,
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// jsonSchemaType returns the JSON Schema of the type the way encoding/json encodes it, structs are added to defs
+// and referenced.
+func jsonSchemaType(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Ptr:
+		return jsonSchemaType(t.Elem(), defs)
+	case t.Kind() == reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": jsonSchemaType(t.Elem(), defs)}
+	case t.Kind() == reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case t.Kind() == reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+		if _, ok := defs[t.Name()]; ok {
+			return ref
+		}
+		// the placeholder stops the recursion of self referencing structs.
+		defs[t.Name()] = nil
+		properties := map[string]interface{}{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, omitempty, ok := jsonField(f)
+			if !ok {
+				continue
+			}
+			property := jsonSchemaType(f.Type, defs)
+			if !omitempty && (f.Type.Kind() == reflect.Ptr || f.Type.Kind() == reflect.Slice) {
+				// nil pointers and slices are encoded as null.
+				property = map[string]interface{}{"anyOf": []interface{}{property, map[string]interface{}{"type": "null"}}}
+			}
+			properties[name] = property
+			if !omitempty {
+				required = append(required, name)
+			}
+		}
+		def := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			def["required"] = required
+		}
+		defs[t.Name()] = def
+		return ref
+	}
+	return map[string]interface{}{}
+}
+
+// jsonField returns the name of the field in the JSON encoding and whether it is omitted when empty, ok is
+// false for fields that are not encoded.
+func jsonField(f reflect.StructField) (name string, omitempty bool, ok bool) {
+	if !f.IsExported() {
+		return "", false, false
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = f.Name
+	}
+	for _, option := range parts[1:] {
+		// encoding/json never omits structs, time.Time included.
+		if option == "omitempty" && f.Type.Kind() != reflect.Struct {
+			omitempty = true
+		}
+	}
+	return name, omitempty, true
+}
+
+// avroNamespace is the namespace of the records of the exported Avro schema.
+const avroNamespace = "dev.sigstore.rekor.phren"
+
+// EntryAvroSchema returns the Avro schema of the BigQuery rows, mapped the way BigQuery exports a table to Avro:
+// nullable columns are unions with null, repeated columns are arrays and timestamps are timestamp-micros.
+// Records are named after their path, e.g. Entry_Rekord_Signature.
+func EntryAvroSchema() ([]byte, error) {
+	fields, err := avroFields("Entry", entrySchema)
+	if err != nil {
+		return nil, err
+	}
+	schema := map[string]interface{}{
+		"type":      "record",
+		"name":      "Entry",
+		"namespace": avroNamespace,
+		"fields":    fields,
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func avroFields(path string, schema bigquery.Schema) ([]interface{}, error) {
+	fields := make([]interface{}, 0, len(schema))
+	for _, f := range schema {
+		var t interface{}
+		switch f.Type {
+		case bigquery.StringFieldType:
+			t = "string"
+		case bigquery.BytesFieldType:
+			t = "bytes"
+		case bigquery.IntegerFieldType:
+			t = "long"
+		case bigquery.FloatFieldType:
+			t = "double"
+		case bigquery.BooleanFieldType:
+			t = "boolean"
+		case bigquery.TimestampFieldType:
+			t = map[string]interface{}{"type": "long", "logicalType": "timestamp-micros"}
+		case bigquery.DateFieldType:
+			t = map[string]interface{}{"type": "int", "logicalType": "date"}
+		case bigquery.RecordFieldType:
+			name := path + "_" + f.Name
+			nested, err := avroFields(name, f.Schema)
+			if err != nil {
+				return nil, err
+			}
+			t = map[string]interface{}{"type": "record", "name": name, "fields": nested}
+		default:
+			return nil, fmt.Errorf("column %s has unsupported type %s", f.Name, f.Type)
+		}
+		field := map[string]interface{}{"name": f.Name}
+		if f.Description != "" {
+			field["doc"] = f.Description
+		}
+		switch {
+		case f.Repeated:
+			field["type"] = map[string]interface{}{"type": "array", "items": t}
+			field["default"] = []interface{}{}
+		case f.Required:
+			field["type"] = t
+		default:
+			field["type"] = []interface{}{"null", t}
+			field["default"] = nil
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
@@ -0,0 +1,172 @@
+package pkg
+
+import (
+	"encoding/json"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func TestEntryBigQuerySchema(t *testing.T) {
+	data, err := EntryBigQuerySchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := bigquery.SchemaFromJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the exported file compares equal to the schema the rows are saved with
+	if changes := DiffEntrySchema(schema); len(changes) != 0 {
+		t.Errorf("DiffEntrySchema() = %v, want no changes", changes)
+	}
+}
+
+func TestDiffEntrySchemaBreaking(t *testing.T) {
+	tests := []struct {
+		name         string
+		change       func(bigquery.Schema) bigquery.Schema
+		wantKind     ChangeKind
+		wantBreaking bool
+	}{
+		{
+			name:     "missing column",
+			change:   func(s bigquery.Schema) bigquery.Schema { return s[1:] },
+			wantKind: ColumnAdded,
+		},
+		{
+			name: "required column",
+			change: func(s bigquery.Schema) bigquery.Schema {
+				f := *s[0]
+				f.Required = true
+				return append(bigquery.Schema{&f}, s[1:]...)
+			},
+			wantKind: ColumnRelaxed,
+		},
+		{
+			name: "extra column",
+			change: func(s bigquery.Schema) bigquery.Schema {
+				return append(s, &bigquery.FieldSchema{Name: "Legacy", Type: bigquery.StringFieldType})
+			},
+			// migrations keep the column, but the rows do not have it anymore
+			wantKind:     ColumnRemoved,
+			wantBreaking: true,
+		},
+		{
+			name: "changed type",
+			change: func(s bigquery.Schema) bigquery.Schema {
+				f := *s[0]
+				f.Type = bigquery.StringFieldType
+				return append(bigquery.Schema{&f}, s[1:]...)
+			},
+			wantKind:     ColumnTypeChanged,
+			wantBreaking: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := tt.change(append(bigquery.Schema{}, entrySchema...))
+			changes := DiffEntrySchema(schema)
+			if len(changes) != 1 {
+				t.Fatalf("DiffEntrySchema() = %v, want one change", changes)
+			}
+			if changes[0].Kind != tt.wantKind {
+				t.Errorf("%s Kind = %s, want %s", changes[0], changes[0].Kind, tt.wantKind)
+			}
+			if changes[0].Breaking() != tt.wantBreaking {
+				t.Errorf("%s Breaking() = %v, want %v", changes[0], changes[0].Breaking(), tt.wantBreaking)
+			}
+		})
+	}
+}
+
+func TestEntryJSONSchema(t *testing.T) {
+	data, err := EntryJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Ref  string `json:"$ref"`
+		Defs map[string]struct {
+			Properties map[string]map[string]interface{} `json:"properties"`
+			Required   []string                          `json:"required"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Ref != "#/$defs/Entry" {
+		t.Errorf("$ref = %s", schema.Ref)
+	}
+	entry, ok := schema.Defs["Entry"]
+	if !ok {
+		t.Fatal("Entry is not defined")
+	}
+	required := map[string]bool{}
+	for _, name := range entry.Required {
+		required[name] = true
+	}
+	if !required["logIndex"] || required["rekord"] {
+		t.Errorf("required = %v, want logIndex and not rekord", entry.Required)
+	}
+	if entry.Properties["date"]["format"] != "date-time" {
+		t.Errorf("date = %v, want a date-time", entry.Properties["date"])
+	}
+	for name, def := range schema.Defs {
+		if def.Properties == nil {
+			t.Errorf("%s has no properties", name)
+		}
+	}
+}
+
+func TestEntryAvroSchema(t *testing.T) {
+	data, err := EntryAvroSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	fields := map[string]interface{}{}
+	var walk func(record map[string]interface{})
+	walk = func(record map[string]interface{}) {
+		name, _ := record["name"].(string)
+		if names[name] {
+			t.Errorf("record %s is defined twice", name)
+		}
+		names[name] = true
+		for _, f := range record["fields"].([]interface{}) {
+			field := f.(map[string]interface{})
+			if name == "Entry" {
+				fields[field["name"].(string)] = field["type"]
+			}
+			types := []interface{}{field["type"]}
+			if union, ok := field["type"].([]interface{}); ok {
+				types = union
+			}
+			for _, t := range types {
+				if m, ok := t.(map[string]interface{}); ok {
+					if m["type"] == "array" {
+						m, _ = m["items"].(map[string]interface{})
+					}
+					if m != nil && m["type"] == "record" {
+						walk(m)
+					}
+				}
+			}
+		}
+	}
+	walk(schema)
+	integratedAt, ok := fields["IntegratedAt"].([]interface{})
+	if !ok || len(integratedAt) != 2 || integratedAt[0] != "null" {
+		t.Fatalf("IntegratedAt = %v, want a nullable union", fields["IntegratedAt"])
+	}
+	if timestamp := integratedAt[1].(map[string]interface{}); timestamp["logicalType"] != "timestamp-micros" {
+		t.Errorf("IntegratedAt = %v, want timestamp-micros", timestamp)
+	}
+	if !names["Entry_Kind"] {
+		t.Errorf("records = %v, want Entry_Kind", names)
+	}
+}
@@ -0,0 +1,292 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SearchOptions configures the OpenSearch/Elasticsearch sink.
+type SearchOptions struct {
+	// URL is the base URL of the cluster, e.g. http://localhost:9200.
+	URL string
+	// Username and Password are used for basic authentication when set.
+	Username string
+	Password string
+	// IndexPrefix is the prefix of the monthly indexes <prefix>-YYYY.MM, defaults to rekor.
+	IndexPrefix string
+	// BatchSize is the number of entries in a bulk request, defaults to 500.
+	BatchSize int
+	// Retries is the number of times a failed bulk request is retried, defaults to 5.
+	Retries int
+	// Backoff is the delay before the first retry, it doubles after every attempt, defaults to 500ms.
+	Backoff time.Duration
+	// Client sends the requests, defaults to a client with a 60s timeout.
+	Client *http.Client
+}
+
+// searchMapping is the explicit mapping of the indexes. Identities and hashes are keyword fields for exact
+// matches, identities also have a text sub field for full-text search. The entry itself is kept in the
+// source but not indexed.
+const searchMapping = `{
+	"dynamic": "strict",
+	"properties": {
+		"log_index":           {"type": "long"},
+		"log_id":              {"type": "keyword"},
+		"integrated_time":     {"type": "date", "format": "epoch_second"},
+		"kind":                {"type": "keyword"},
+		"api_version":         {"type": "keyword"},
+		"data_hash_algorithm": {"type": "keyword"},
+		"data_hash_value":     {"type": "keyword"},
+		"key_fingerprint":     {"type": "keyword"},
+		"issuer":              {"type": "keyword"},
+		"identities":          {"type": "keyword", "fields": {"text": {"type": "text"}}},
+		"subject_alternative_names": {"type": "keyword", "fields": {"text": {"type": "text"}}},
+		"pgp_uids":            {"type": "keyword", "fields": {"text": {"type": "text"}}},
+		"pgp_emails":          {"type": "keyword"},
+		"extensions": {
+			"type": "nested",
+			"properties": {
+				"id":    {"type": "keyword"},
+				"value": {"type": "keyword", "fields": {"text": {"type": "text"}}}
+			}
+		},
+		"decoder_version":     {"type": "integer"},
+		"parse_error":         {"type": "text"},
+		"entry":               {"type": "object", "enabled": false}
+	}
+}`
+
+// searchDocument is the document indexed for an entry.
+type searchDocument struct {
+	LogIndex                int             `json:"log_index"`
+	LogID                   string          `json:"log_id"`
+	IntegratedTime          int             `json:"integrated_time"`
+	Kind                    string          `json:"kind,omitempty"`
+	APIVersion              string          `json:"api_version,omitempty"`
+	DataHashAlgorithm       string          `json:"data_hash_algorithm,omitempty"`
+	DataHashValue           string          `json:"data_hash_value,omitempty"`
+	KeyFingerprint          string          `json:"key_fingerprint,omitempty"`
+	Issuer                  string          `json:"issuer,omitempty"`
+	Identities              []string        `json:"identities,omitempty"`
+	SubjectAlternativeNames []string        `json:"subject_alternative_names,omitempty"`
+	PGPUIDs                 []string        `json:"pgp_uids,omitempty"`
+	PGPEmails               []string        `json:"pgp_emails,omitempty"`
+	Extensions              []X509Extension `json:"extensions,omitempty"`
+	DecoderVersion          int             `json:"decoder_version"`
+	ParseError              string          `json:"parse_error,omitempty"`
+	Entry                   Entry           `json:"entry"`
+}
+
+func newSearchDocument(e Entry) searchDocument {
+	sig, _ := signatureOf(e)
+	doc := searchDocument{
+		LogIndex:                e.LogIndex,
+		LogID:                   e.LogID,
+		IntegratedTime:          e.IntegratedTime,
+		Kind:                    e.Kind.Kind,
+		APIVersion:              e.Kind.APIVersion,
+		DataHashAlgorithm:       sig.Hash.Algorithm,
+		DataHashValue:           sig.Hash.Value,
+		KeyFingerprint:          sig.fingerprint(),
+		Issuer:                  sig.issuer(),
+		Identities:              sig.identities(),
+		SubjectAlternativeNames: sig.subjectAlternativeNames(),
+		DecoderVersion:          e.DecoderVersion,
+		ParseError:              e.ParseError,
+		Entry:                   e,
+	}
+	for _, key := range sig.PGPKeys {
+		for _, uid := range key.UIDs {
+			doc.PGPUIDs = append(doc.PGPUIDs, pgpUIDString(uid))
+			if uid.Email != "" {
+				doc.PGPEmails = append(doc.PGPEmails, uid.Email)
+			}
+		}
+	}
+	if sig.X509 != nil {
+		doc.Extensions = sig.X509.Extensions
+	}
+	return doc
+}
+
+type searchSink struct {
+	opts SearchOptions
+	// sendMu serializes the bulk requests so Flush waits for a batch a writer is sending, it is taken before mu.
+	sendMu sync.Mutex
+	mu     sync.Mutex
+	batch  []Entry
+}
+
+// NewSearchSink returns a Sink that bulk-indexes the entries into OpenSearch or Elasticsearch. It installs an
+// index template with the explicit mapping, entries go to the index of the month they were integrated in
+// and use the log index as document id, so writing an entry again replaces it.
+func NewSearchSink(opts SearchOptions) (Sink, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("search url is required")
+	}
+	opts.URL = strings.TrimRight(opts.URL, "/")
+	if opts.IndexPrefix == "" {
+		opts.IndexPrefix = "rekor"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.Retries <= 0 {
+		opts.Retries = 5
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 500 * time.Millisecond
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 60 * time.Second}
+	}
+	s := &searchSink{opts: opts}
+	if err := s.putTemplate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// putTemplate installs the index template that applies the mapping to every monthly index.
+func (s *searchSink) putTemplate() error {
+	template := fmt.Sprintf(`{"index_patterns": [%q], "template": {"mappings": %s}}`,
+		s.opts.IndexPrefix+"-*", searchMapping)
+	_, err := s.do(http.MethodPut, "/_index_template/"+s.opts.IndexPrefix, "application/json", []byte(template))
+	if err != nil {
+		return fmt.Errorf("failed to put index template %w", err)
+	}
+	return nil
+}
+
+// searchIndex returns the monthly index of the entry.
+func (s *searchSink) searchIndex(e Entry) string {
+	return s.opts.IndexPrefix + "-" + time.Unix(int64(e.IntegratedTime), 0).UTC().Format("2006.01")
+}
+
+func (s *searchSink) Write(entry Entry) error {
+	return s.WriteBatch([]Entry{entry})
+}
+
+func (s *searchSink) WriteBatch(entries []Entry) error {
+	s.mu.Lock()
+	s.batch = append(s.batch, entries...)
+	if len(s.batch) < s.opts.BatchSize {
+		s.mu.Unlock()
+		return nil
+	}
+	batch := s.take()
+	s.mu.Unlock()
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	return s.send(batch)
+}
+
+func (s *searchSink) Flush() error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	s.mu.Lock()
+	batch := s.take()
+	s.mu.Unlock()
+	return s.send(batch)
+}
+
+// take returns the batch, it is called with mu held.
+func (s *searchSink) take() []Entry {
+	batch := s.batch
+	s.batch = nil
+	return batch
+}
+
+// bulkResponse is the part of the _bulk response needed to find the failed items.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		ID     string          `json:"_id"`
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// send sends the batch as a single bulk request, it is called with sendMu held so writers only wait for mu
+// while the request and its retries are in flight. The batch is dropped after it was sent or the retries
+// are exhausted, so a cluster that is down does not make it grow with every write.
+func (s *searchSink) send(batch []Entry) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	var body bytes.Buffer
+	for _, entry := range batch {
+		action := fmt.Sprintf(`{"index":{"_index":%q,"_id":%q}}`, s.searchIndex(entry), strconv.Itoa(entry.LogIndex))
+		doc, err := Marshal(newSearchDocument(entry))
+		if err != nil {
+			return fmt.Errorf("failed to encode entry %d %w", entry.LogIndex, err)
+		}
+		body.WriteString(action)
+		body.WriteByte('\n')
+		body.Write(doc)
+		body.WriteByte('\n')
+	}
+	var resp []byte
+	err := retry(s.opts.Retries, s.opts.Backoff, func() error {
+		var err error
+		resp, err = s.do(http.MethodPost, "/_bulk", "application/x-ndjson", body.Bytes())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to index %d entries %w", len(batch), err)
+	}
+	var result bulkResponse
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+	var errs []error
+	for _, item := range result.Items {
+		for _, r := range item {
+			if r.Status < 200 || r.Status > 299 {
+				errs = append(errs, fmt.Errorf("entry %s: %s", r.ID, r.Error))
+			}
+		}
+	}
+	return fmt.Errorf("failed to index %d of %d entries %w", len(errs), len(batch), errors.Join(errs...))
+}
+
+// do sends the request and returns the response body, non 2xx responses are errors.
+func (s *searchSink) do(method, path, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, s.opts.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.opts.Username != "" {
+		req.SetBasicAuth(s.opts.Username, s.opts.Password)
+	}
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	//nolint
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s: unexpected status %s %s", method, path, resp.Status, data)
+	}
+	return data, nil
+}
+
+func (s *searchSink) Close() error {
+	return s.Flush()
+}
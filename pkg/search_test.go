@@ -0,0 +1,293 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewSearchDocument(t *testing.T) {
+	doc := newSearchDocument(Entry{LogIndex: 3, Kind: Kind{Kind: "rekord"}, Rekord: &Rekord{
+		Data: RekordData{Hash: RekorDataHash{Algorithm: "sha256", Value: "abc"}},
+		Signature: Signature{
+			PGPKeys: []PGPKey{{Fingerprint: "ABC", UIDs: []PGPUID{{Name: "Jane", Comment: "work", Email: "jane@example.com"}}}},
+			X509: &X509{IssuerOrganization: "sigstore.dev",
+				Extensions: []X509Extension{{ID: "1.3.6.1.4.1.57264.1.5", Value: "sigstore/cosign"}}},
+		},
+	}})
+	if doc.DataHashValue != "abc" || doc.Issuer != "sigstore.dev" || len(doc.Extensions) != 1 {
+		t.Errorf("newSearchDocument() = %+v", doc)
+	}
+	if !reflect.DeepEqual(doc.PGPUIDs, []string{"Jane (work) <jane@example.com>"}) ||
+		!reflect.DeepEqual(doc.PGPEmails, []string{"jane@example.com"}) {
+		t.Errorf("newSearchDocument() pgp uids %v, emails %v", doc.PGPUIDs, doc.PGPEmails)
+	}
+}
+
+// searchServer is a minimal _index_template and _bulk endpoint. It rejects documents with fields that are
+// not in searchMapping, like a cluster with the strict mapping does.
+type searchServer struct {
+	mu          sync.Mutex
+	templates   map[string]string
+	docs        map[string]map[string]json.RawMessage
+	fail        string
+	unavailable bool
+	// mapping are the mapping errors of the rejected documents.
+	mapping []string
+}
+
+// searchField is the part of a field mapping needed to check documents against it.
+type searchField struct {
+	Enabled    *bool                  `json:"enabled"`
+	Properties map[string]searchField `json:"properties"`
+}
+
+// checkMapping returns an error for the first field of the document that is not in the mapping.
+func checkMapping(properties map[string]searchField, doc map[string]interface{}, path string) error {
+	for name, value := range doc {
+		field, ok := properties[name]
+		if !ok {
+			return fmt.Errorf("mapping set to strict, dynamic introduction of [%s%s] is not allowed", path, name)
+		}
+		if field.Properties == nil || (field.Enabled != nil && !*field.Enabled) {
+			continue
+		}
+		values := []interface{}{value}
+		if a, ok := value.([]interface{}); ok {
+			values = a
+		}
+		for _, v := range values {
+			object, ok := v.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s%s is not an object", path, name)
+			}
+			if err := checkMapping(field.Properties, object, path+name+"."); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *searchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/_index_template/"):
+		if !json.Valid(body) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.templates[strings.TrimPrefix(r.URL.Path, "/_index_template/")] = string(body)
+	case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+		if s.unavailable {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var mapping searchField
+		if err := json.Unmarshal([]byte(searchMapping), &mapping); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var items []string
+		errors := false
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			var action struct {
+				Index struct {
+					Index string `json:"_index"`
+					ID    string `json:"_id"`
+				} `json:"index"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &action); err != nil || !scanner.Scan() {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			status := 201
+			var doc map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if err := checkMapping(mapping.Properties, doc, ""); err != nil {
+				s.mapping = append(s.mapping, err.Error())
+				status, errors = 400, true
+			} else if action.Index.ID == s.fail {
+				status, errors = 400, true
+			} else {
+				if s.docs[action.Index.Index] == nil {
+					s.docs[action.Index.Index] = map[string]json.RawMessage{}
+				}
+				s.docs[action.Index.Index][action.Index.ID] = append(json.RawMessage{}, scanner.Bytes()...)
+			}
+			items = append(items, fmt.Sprintf(`{"index":{"_id":%q,"status":%d}}`, action.Index.ID, status))
+		}
+		fmt.Fprintf(w, `{"errors":%v,"items":[%s]}`, errors, strings.Join(items, ","))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestSearchSink(t *testing.T) {
+	server := &searchServer{templates: map[string]string{}, docs: map[string]map[string]json.RawMessage{}, fail: "9"}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	sink, err := NewSearchSink(SearchOptions{URL: ts.URL, BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(server.templates["rekor"], `"rekor-*"`) {
+		t.Errorf("template = %s", server.templates["rekor"])
+	}
+	jan := int(time.Date(2023, 1, 31, 23, 0, 0, 0, time.UTC).Unix())
+	feb := int(time.Date(2023, 2, 1, 1, 0, 0, 0, time.UTC).Unix())
+	cert := &Rekord{Signature: Signature{X509: &X509{IssuerOrganization: "sigstore.dev",
+		Extensions: []X509Extension{{ID: "1.3.6.1.4.1.57264.1.5", Value: "sigstore/cosign"}}}}}
+	err = sink.WriteBatch([]Entry{{LogIndex: 1, IntegratedTime: jan}, {LogIndex: 2, IntegratedTime: feb, Rekord: cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(server.mapping) != 0 {
+		t.Errorf("documents do not match the mapping: %v", server.mapping)
+	}
+	if len(server.docs["rekor-2023.01"]) != 1 || len(server.docs["rekor-2023.02"]) != 1 {
+		t.Errorf("indexes = %v", server.docs)
+	}
+	if err := sink.Write(Entry{LogIndex: 9, IntegratedTime: feb}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err == nil || !strings.Contains(err.Error(), "entry 9") {
+		t.Errorf("Close() = %v, want the error of entry 9", err)
+	}
+}
+
+func TestSearchSinkUnavailable(t *testing.T) {
+	server := &searchServer{templates: map[string]string{}, docs: map[string]map[string]json.RawMessage{}}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	sink, err := NewSearchSink(SearchOptions{URL: ts.URL, BatchSize: 1, Retries: 1, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.unavailable = true
+	if err := sink.Write(Entry{LogIndex: 1}); err == nil {
+		t.Fatal("Write() = nil, want an error while the cluster is unavailable")
+	}
+	server.unavailable = false
+	if err := sink.Write(Entry{LogIndex: 2}); err != nil {
+		t.Fatal(err)
+	}
+	// the failed batch was dropped, it is not sent again with the next one
+	if docs := server.docs["rekor-1970.01"]; len(docs) != 1 || docs["2"] == nil {
+		t.Errorf("documents = %v, want only entry 2", docs)
+	}
+}
+
+func TestSearchSinkSlowCluster(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan struct{}, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_bulk" {
+			select {
+			case received <- struct{}{}:
+			default:
+			}
+			<-release
+		}
+		fmt.Fprint(w, `{"errors":false}`)
+	}))
+	defer ts.Close()
+	sink, err := NewSearchSink(SearchOptions{URL: ts.URL, BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := make(chan error, 1)
+	go func() { sent <- sink.WriteBatch([]Entry{{LogIndex: 1}, {LogIndex: 2}}) }()
+	<-received
+	// the full batch is in flight, a write that does not fill the next batch must not wait for it
+	written := make(chan error, 1)
+	go func() { written <- sink.Write(Entry{LogIndex: 3}) }()
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("Write blocked while a bulk request was in flight")
+	}
+	close(release)
+	if err := <-sent; err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSearchSinkCluster needs a single node OpenSearch or Elasticsearch, e.g.
+//
+//	docker run -d -p 9200:9200 -e discovery.type=single-node -e DISABLE_SECURITY_PLUGIN=true opensearchproject/opensearch:2
+//	PHREN_TEST_SEARCH_URL=http://localhost:9200 go test ./pkg -run SearchSinkCluster
+func TestSearchSinkCluster(t *testing.T) {
+	url := os.Getenv("PHREN_TEST_SEARCH_URL")
+	if url == "" {
+		t.Skip("PHREN_TEST_SEARCH_URL is not set")
+	}
+	prefix := fmt.Sprintf("phren-test-%d", time.Now().UnixNano())
+	sink, err := NewSearchSink(SearchOptions{URL: url, IndexPrefix: prefix})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sink.(*searchSink)
+	t.Cleanup(func() {
+		//nolint
+		s.do(http.MethodDelete, "/"+prefix+"-*", "application/json", nil)
+		//nolint
+		s.do(http.MethodDelete, "/_index_template/"+prefix, "application/json", nil)
+	})
+	entry := Entry{LogIndex: 5, IntegratedTime: int(time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC).Unix()),
+		Kind: Kind{Kind: "rekord"}, Rekord: &Rekord{Signature: Signature{
+			PGPKeys: []PGPKey{{UIDs: []PGPUID{{Name: "Jane", Email: "jane@example.com"}}}},
+		}}}
+	if err := sink.Write(entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.do(http.MethodPost, "/"+prefix+"-*/_refresh", "application/json", nil); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.do(http.MethodPost, "/"+prefix+"-*/_search", "application/json",
+		[]byte(`{"query":{"term":{"pgp_emails":"jane@example.com"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Index string `json:"_index"`
+				ID    string `json:"_id"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Hits.Hits) != 1 || result.Hits.Hits[0].ID != "5" || result.Hits.Hits[0].Index != prefix+"-2023.03" {
+		t.Errorf("search = %s", resp)
+	}
+}
@@ -0,0 +1,229 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// Sink is a destination for decoded rekor entries.
+type Sink interface {
+	// Write writes a single entry.
+	Write(entry Entry) error
+	// WriteBatch writes the entries in one go where the destination supports it.
+	WriteBatch(entries []Entry) error
+	// Flush writes any buffered entries.
+	Flush() error
+	// Close flushes and releases the resources held by the sink.
+	Close() error
+}
+
+// Store is a Sink that also keeps the state phren needs to resume, such as the last and the missing entries.
+type Store interface {
+	Sink
+	Phren
+}
+
+// RawSink is implemented by sinks that also archive the unmodified rekor API response.
+type RawSink interface {
+	WriteRaw(index int64, raw []byte) error
+}
+
+// BigQueryOptions configures the BigQuery sink.
+type BigQueryOptions struct {
+	Dataset string
+	Table   string
+	// BatchSize is the number of rows buffered before they are inserted, defaults to 500.
+	BatchSize int
+	// FlushInterval is the time after which buffered rows are inserted even if the batch is not full,
+	// defaults to 10s.
+	FlushInterval time.Duration
+}
+
+// rowInserter is the part of bigquery.Inserter used by the sink.
+type rowInserter interface {
+	Put(ctx context.Context, src interface{}) error
+}
+
+type bigQuerySink struct {
+	inserter rowInserter
+	opts     BigQueryOptions
+	mu       sync.Mutex
+	batch    []Entry
+	timer    *time.Timer
+	// err is the error of a batch inserted by the timer, it is returned by the next call.
+	err error
+}
+
+// NewBigQuerySink returns a Sink that inserts the entries into the BigQuery table with the client of the
+// repository. It buffers the rows, they are inserted once BatchSize is reached or FlushInterval has passed.
+func NewBigQuerySink(bq *BigQuery, opts BigQueryOptions) (Sink, error) {
+	if bq == nil {
+		return nil, fmt.Errorf("bigquery repository is required")
+	}
+	if opts.Dataset == "" {
+		return nil, fmt.Errorf("dataset is required")
+	}
+	if opts.Table == "" {
+		return nil, fmt.Errorf("table is required")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 10 * time.Second
+	}
+	return &bigQuerySink{
+		inserter: bq.client.Dataset(opts.Dataset).Table(opts.Table).Inserter(),
+		opts:     opts,
+	}, nil
+}
+
+func (s *bigQuerySink) Write(entry Entry) error {
+	return s.WriteBatch([]Entry{entry})
+}
+
+func (s *bigQuerySink) WriteBatch(entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.takeErr(); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if len(s.batch) == 0 {
+			s.timer = time.AfterFunc(s.opts.FlushInterval, s.timedFlush)
+		}
+		s.batch = append(s.batch, entry)
+		if len(s.batch) >= s.opts.BatchSize {
+			if err := s.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// timedFlush inserts the buffered rows once FlushInterval has passed.
+func (s *bigQuerySink) timedFlush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.flush(); err != nil && s.err == nil {
+		s.err = err
+	}
+}
+
+func (s *bigQuerySink) takeErr() error {
+	err := s.err
+	s.err = nil
+	return err
+}
+
+// Flush inserts the buffered rows and returns the error of a failed timed flush along with its own.
+func (s *bigQuerySink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.takeErr()
+	return errors.Join(err, s.flush())
+}
+
+// flush inserts the buffered rows in requests of at most BatchSize rows. The rows of a failed request stay
+// buffered and are inserted again by the next flush, their insert ids keep BigQuery from storing them twice.
+func (s *bigQuerySink) flush() error {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	for len(s.batch) > 0 {
+		batch := s.batch[:min(len(s.batch), s.opts.BatchSize)]
+		if err := s.inserter.Put(context.Background(), entrySavers(batch)); err != nil {
+			s.batch = append(retryableRows(batch, err), s.batch[len(batch):]...)
+			if len(s.batch) > 0 {
+				s.timer = time.AfterFunc(s.opts.FlushInterval, s.timedFlush)
+			}
+			return fmt.Errorf("failed to insert %d entries %d-%d %w", len(batch), batch[0].LogIndex,
+				batch[len(batch)-1].LogIndex, err)
+		}
+		s.batch = s.batch[len(batch):]
+	}
+	s.batch = nil
+	return nil
+}
+
+// retryableRows returns the rows of a failed insert that are worth inserting again. That is all of them,
+// unless BigQuery reported errors for single rows, then the rows it rejected as invalid are dropped.
+func retryableRows(batch []Entry, err error) []Entry {
+	var rowErrs bigquery.PutMultiError
+	if !errors.As(err, &rowErrs) {
+		return append([]Entry(nil), batch...)
+	}
+	invalid := map[int]bool{}
+	for _, rowErr := range rowErrs {
+		for _, err := range rowErr.Errors {
+			var bqErr *bigquery.Error
+			if errors.As(err, &bqErr) && bqErr.Reason == "invalid" {
+				invalid[rowErr.RowIndex] = true
+			}
+		}
+	}
+	var rows []Entry
+	for i, entry := range batch {
+		if !invalid[i] {
+			rows = append(rows, entry)
+		}
+	}
+	return rows
+}
+
+// Close flushes the buffered rows, the client is closed with the repository.
+func (s *bigQuerySink) Close() error {
+	return s.Flush()
+}
+
+type bucketSink struct {
+	bucket Bucket
+}
+
+// NewBucketSink returns a Sink that writes <index>/entry.json and <index>/raw.json objects to the bucket.
+func NewBucketSink(bucket Bucket) (Sink, error) {
+	if bucket == nil {
+		return nil, fmt.Errorf("bucket is required")
+	}
+	return &bucketSink{bucket: bucket}, nil
+}
+
+func (s *bucketSink) Write(entry Entry) error {
+	return s.bucket.UpdateBucket(entry)
+}
+
+func (s *bucketSink) WriteRaw(index int64, raw []byte) error {
+	return s.bucket.UpdateBucketRaw(index, raw)
+}
+
+func (s *bucketSink) WriteBatch(entries []Entry) error {
+	var errs []error
+	for _, entry := range entries {
+		if err := s.Write(entry); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update bucket %d %w", entry.LogIndex, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *bucketSink) Flush() error {
+	return nil
+}
+
+func (s *bucketSink) Close() error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	if closer, ok := s.bucket.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
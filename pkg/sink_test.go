@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// fakeInserter records the sizes of the batches and the insert ids it is given. The first Puts fail with
+// errs, their rows are not recorded.
+type fakeInserter struct {
+	mu        sync.Mutex
+	sizes     []int
+	insertIDs []string
+	errs      []error
+}
+
+func (f *fakeInserter) Put(ctx context.Context, src interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return err
+	}
+	savers := src.([]bigquery.ValueSaver)
+	f.sizes = append(f.sizes, len(savers))
+	for _, saver := range savers {
+		_, id, err := saver.Save()
+		if err != nil {
+			return err
+		}
+		f.insertIDs = append(f.insertIDs, id)
+	}
+	return nil
+}
+
+func (f *fakeInserter) batches() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int(nil), f.sizes...)
+}
+
+func TestBigQuerySinkBatching(t *testing.T) {
+	inserter := &fakeInserter{}
+	s := &bigQuerySink{inserter: inserter, opts: BigQueryOptions{BatchSize: 3, FlushInterval: time.Hour}}
+	for i := 0; i < 7; i++ {
+		if err := s.Write(Entry{LogIndex: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := inserter.batches(); !reflect.DeepEqual(got, []int{3, 3}) {
+		t.Errorf("batches before Flush = %v, want [3 3]", got)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := inserter.batches(); !reflect.DeepEqual(got, []int{3, 3, 1}) {
+		t.Errorf("batches after Flush = %v, want [3 3 1]", got)
+	}
+}
+
+func TestBigQuerySinkInsertID(t *testing.T) {
+	inserter := &fakeInserter{}
+	s := &bigQuerySink{inserter: inserter, opts: BigQueryOptions{BatchSize: 100, FlushInterval: time.Hour}}
+	entry := Entry{LogID: "c0d23d6a", LogIndex: 42, Kind: Kind{Kind: "rekord"}}
+	// the same entry written twice has the same insert id, BigQuery keeps a single row
+	if err := s.WriteBatch([]Entry{entry, entry}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c0d23d6a-42", "c0d23d6a-42"}; !reflect.DeepEqual(inserter.insertIDs, want) {
+		t.Errorf("insert ids = %v, want %v", inserter.insertIDs, want)
+	}
+}
+
+func TestBigQuerySinkFlushInterval(t *testing.T) {
+	inserter := &fakeInserter{}
+	s := &bigQuerySink{inserter: inserter, opts: BigQueryOptions{BatchSize: 100, FlushInterval: 10 * time.Millisecond}}
+	if err := s.WriteBatch([]Entry{{LogIndex: 1}, {LogIndex: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(inserter.batches()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := inserter.batches(); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("batches = %v, want [2]", got)
+	}
+}
+
+func TestBigQuerySinkRetry(t *testing.T) {
+	rowErr := func(row int, reason string) bigquery.RowInsertionError {
+		return bigquery.RowInsertionError{RowIndex: row, Errors: bigquery.MultiError{&bigquery.Error{Reason: reason}}}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want []string
+	}{
+		{
+			name: "request failed",
+			err:  errors.New("connection reset"),
+			want: []string{"log-0", "log-1", "log-2", "log-3"},
+		},
+		{
+			name: "invalid row",
+			err:  bigquery.PutMultiError{rowErr(0, "stopped"), rowErr(1, "invalid"), rowErr(2, "stopped")},
+			want: []string{"log-0", "log-2", "log-3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inserter := &fakeInserter{errs: []error{tt.err}}
+			s := &bigQuerySink{inserter: inserter, opts: BigQueryOptions{BatchSize: 3, FlushInterval: time.Hour}}
+			entries := []Entry{{LogID: "log", LogIndex: 0}, {LogID: "log", LogIndex: 1}, {LogID: "log", LogIndex: 2}}
+			if err := s.WriteBatch(entries); err == nil {
+				t.Fatal("WriteBatch() = nil, want the insert error")
+			}
+			// the rows of the failed request are inserted with the next one
+			if err := s.Write(Entry{LogID: "log", LogIndex: 3}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(inserter.insertIDs, tt.want) {
+				t.Errorf("insert ids = %v, want %v", inserter.insertIDs, tt.want)
+			}
+		})
+	}
+}
+
+func TestBigQuerySinkCloseAfterTimedFlushError(t *testing.T) {
+	inserter := &fakeInserter{errs: []error{errors.New("connection reset")}}
+	s := &bigQuerySink{inserter: inserter, opts: BigQueryOptions{BatchSize: 100, FlushInterval: time.Hour}}
+	if err := s.WriteBatch([]Entry{{LogID: "log", LogIndex: 1}, {LogID: "log", LogIndex: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	// the flush the timer runs fails, its error is kept for the next call
+	s.timedFlush()
+	if err := s.Close(); err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("Close() = %v, want the error of the timed flush", err)
+	}
+	if want := []string{"log-1", "log-2"}; !reflect.DeepEqual(inserter.insertIDs, want) {
+		t.Errorf("insert ids = %v, want %v", inserter.insertIDs, want)
+	}
+}
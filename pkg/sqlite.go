@@ -0,0 +1,254 @@
+package pkg
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	// pure Go sqlite driver, registers "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations are applied in order, the number of applied migrations is kept in schema_migrations.
+// Never change a migration that has been released, append a new one instead.
+var sqliteMigrations = []string{
+	`CREATE TABLE entries (
+		log_index           INTEGER PRIMARY KEY,
+		log_id              TEXT NOT NULL,
+		integrated_time     INTEGER NOT NULL,
+		kind                TEXT,
+		api_version         TEXT,
+		data_hash_algorithm TEXT,
+		data_hash_value     TEXT,
+		decoder_version     INTEGER,
+		parse_error         TEXT,
+		body                TEXT,
+		date                TEXT
+	);
+	CREATE INDEX entries_data_hash_value ON entries (data_hash_value);
+	CREATE TABLE signatures (
+		log_index          INTEGER PRIMARY KEY REFERENCES entries (log_index) ON DELETE CASCADE,
+		format             TEXT,
+		public_key         TEXT,
+		key_algorithm      TEXT,
+		key_curve          TEXT,
+		key_bits           INTEGER,
+		key_fingerprint    TEXT
+	);
+	CREATE INDEX signatures_key_fingerprint ON signatures (key_fingerprint);
+	CREATE TABLE certificates (
+		log_index           INTEGER PRIMARY KEY REFERENCES entries (log_index) ON DELETE CASCADE,
+		version             INTEGER,
+		serial_number       TEXT,
+		signature_algorithm TEXT,
+		issuer_organization TEXT,
+		issuer_common_name  TEXT,
+		not_before          TEXT,
+		not_after           TEXT
+	);
+	CREATE TABLE extensions (
+		log_index INTEGER NOT NULL REFERENCES entries (log_index) ON DELETE CASCADE,
+		oid       TEXT NOT NULL,
+		value     TEXT
+	);
+	CREATE INDEX extensions_log_index ON extensions (log_index);
+	CREATE INDEX extensions_oid_value ON extensions (oid, value);
+	CREATE TABLE pgp_identities (
+		log_index       INTEGER NOT NULL REFERENCES entries (log_index) ON DELETE CASCADE,
+		key_fingerprint TEXT,
+		key_id          TEXT,
+		name            TEXT,
+		comment         TEXT,
+		email           TEXT
+	);
+	CREATE INDEX pgp_identities_log_index ON pgp_identities (log_index);
+	CREATE INDEX pgp_identities_email ON pgp_identities (email);`,
+}
+
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens or creates the SQLite database at path, migrates its schema and returns a Store
+// that writes the entries into normalized tables keyed by log index.
+func NewSQLiteStore(path string) (Store, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite database path is required")
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(10000)")
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open: %w", err)
+	}
+	// sqlite allows a single writer, serialize the connections instead of failing with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	s := &sqliteStore{db: db}
+	if err := s.migrate(); err != nil {
+		//nolint
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// migrate applies the migrations that have not been applied yet.
+func (s *sqliteStore) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL, applied_at TEXT NOT NULL)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	var version int
+	if err := s.db.QueryRow(`SELECT IFNULL(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			//nolint
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+			i+1, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			//nolint
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) Write(entry Entry) error {
+	return s.WriteBatch([]Entry{entry})
+}
+
+// WriteBatch upserts the entries in a single transaction.
+func (s *sqliteStore) WriteBatch(entries []Entry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := upsertSQLite(tx, entry); err != nil {
+			//nolint
+			tx.Rollback()
+			return fmt.Errorf("failed to write entry %d %w", entry.LogIndex, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// upsertSQLite replaces the rows of the entry.
+func upsertSQLite(tx *sql.Tx, e Entry) error {
+	sig, ok := signatureOf(e)
+	_, err := tx.Exec(`INSERT INTO entries (log_index, log_id, integrated_time, kind, api_version, data_hash_algorithm,
+			data_hash_value, decoder_version, parse_error, body, date)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (log_index) DO UPDATE SET log_id = excluded.log_id, integrated_time = excluded.integrated_time,
+			kind = excluded.kind, api_version = excluded.api_version, data_hash_algorithm = excluded.data_hash_algorithm,
+			data_hash_value = excluded.data_hash_value, decoder_version = excluded.decoder_version,
+			parse_error = excluded.parse_error, body = excluded.body, date = excluded.date`,
+		e.LogIndex, e.LogID, e.IntegratedTime, e.Kind.Kind, e.Kind.APIVersion, sig.Hash.Algorithm, sig.Hash.Value,
+		e.DecoderVersion, e.ParseError, e.Body, sqliteTime(e.Date))
+	if err != nil {
+		return err
+	}
+	for _, table := range []string{"signatures", "certificates", "extensions", "pgp_identities"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE log_index = ?`, e.LogIndex); err != nil { //nolint:gosec
+			return err
+		}
+	}
+	if !ok {
+		return nil
+	}
+	info := sig.PublicKeyInfo
+	if info == nil {
+		info = &PublicKeyInfo{}
+	}
+	if _, err := tx.Exec(`INSERT INTO signatures (log_index, format, public_key, key_algorithm, key_curve, key_bits,
+			key_fingerprint) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		e.LogIndex, sig.Format, sig.PublicKey, info.Algorithm, info.Curve, info.Bits, info.FingerprintSHA256); err != nil {
+		return err
+	}
+	if c := sig.X509; c != nil {
+		if _, err := tx.Exec(`INSERT INTO certificates (log_index, version, serial_number, signature_algorithm,
+				issuer_organization, issuer_common_name, not_before, not_after) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.LogIndex, c.Version, c.SerialNumber, c.SignatureAlgorithm, c.IssuerOrganization, c.IssuerCommonName,
+			sqliteTime(c.ValidityNotBefore), sqliteTime(c.ValidityNotAfter)); err != nil {
+			return err
+		}
+		for _, ext := range c.Extensions {
+			if _, err := tx.Exec(`INSERT INTO extensions (log_index, oid, value) VALUES (?, ?, ?)`,
+				e.LogIndex, ext.ID, ext.Value); err != nil {
+				return err
+			}
+		}
+	}
+	for _, key := range sig.PGPKeys {
+		for _, uid := range key.UIDs {
+			if _, err := tx.Exec(`INSERT INTO pgp_identities (log_index, key_fingerprint, key_id, name, comment, email)
+					VALUES (?, ?, ?, ?, ?, ?)`,
+				e.LogIndex, key.Fingerprint, key.KeyID, uid.Name, uid.Comment, uid.Email); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sqliteTime formats the time as RFC 3339, the zero time is stored as NULL.
+func sqliteTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func (s *sqliteStore) Flush() error {
+	return nil
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// GetLastEntry returns the highest log index in the database. The dataset and table are ignored.
+func (s *sqliteStore) GetLastEntry(dataset, table string) (int64, error) {
+	var max int64
+	if err := s.db.QueryRow(`SELECT IFNULL(MAX(log_index), 0) FROM entries`).Scan(&max); err != nil {
+		return 0, fmt.Errorf("failed to get last entry: %w", err)
+	}
+	return max, nil
+}
+
+// GetMissingEntries returns the log indexes between 0 and the highest log index that are not in the database.
+// The dataset and table are ignored.
+func (s *sqliteStore) GetMissingEntries(dataset, table string) ([]int64, error) {
+	rows, err := s.db.Query(`
+		SELECT -1, MIN(log_index) FROM entries HAVING MIN(log_index) > 0
+		UNION ALL
+		SELECT log_index, next FROM (
+			SELECT log_index, LEAD(log_index) OVER (ORDER BY log_index) AS next FROM entries
+		) WHERE next > log_index + 1
+		ORDER BY 1`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get missing entries: %w", err)
+	}
+	//nolint
+	defer rows.Close()
+	var missing []int64
+	for rows.Next() {
+		var from, to int64
+		if err := rows.Scan(&from, &to); err != nil {
+			return nil, err
+		}
+		for i := from + 1; i < to; i++ {
+			missing = append(missing, i)
+		}
+	}
+	return missing, rows.Err()
+}
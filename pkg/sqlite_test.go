@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "phren.db")
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []Entry{
+		{LogIndex: 2, Kind: Kind{Kind: "hashedrekord"}, HashedRekord: &Hashedrekord{
+			Signature: RekordSignature{X509: &X509{IssuerOrganization: "sigstore.dev",
+				Extensions: []X509Extension{{ID: "1.3.6.1.4.1.57264.1.1", Value: "https://token.actions.githubusercontent.com"}}}},
+		}},
+		{LogIndex: 3, Kind: Kind{Kind: "rekord"}, Rekord: &Rekord{
+			Signature: Signature{PGPKeys: []PGPKey{{Fingerprint: "ABC", UIDs: []PGPUID{{Name: "Jane", Email: "jane@example.com"}}}}},
+		}},
+		{LogIndex: 6, ParseError: "error decoding base64", Body: "!"},
+	}
+	if err := store.WriteBatch(entries); err != nil {
+		t.Fatal(err)
+	}
+	// writing an entry again replaces its rows
+	if err := store.Write(entries[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopening does not re-apply the migrations
+	store, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	last, err := store.GetLastEntry("", "")
+	if err != nil || last != 6 {
+		t.Errorf("GetLastEntry() = %d, %v, want 6", last, err)
+	}
+	missing, err := store.GetMissingEntries("", "")
+	if err != nil || !reflect.DeepEqual(missing, []int64{0, 1, 4, 5}) {
+		t.Errorf("GetMissingEntries() = %v, %v", missing, err)
+	}
+	db := store.(*sqliteStore).db
+	for table, want := range map[string]int{"entries": 3, "signatures": 2, "certificates": 1, "extensions": 1, "pgp_identities": 1} {
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil || n != want {
+			t.Errorf("%s has %d rows, want %d (%v)", table, n, want, err)
+		}
+	}
+}
@@ -0,0 +1,520 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// StorageWriteOptions configures the BigQuery Storage Write API sink.
+type StorageWriteOptions struct {
+	Dataset string
+	Table   string
+	// Start is the first log index that is written, the offset of an entry in the stream is its log index minus Start.
+	Start int64
+	// StateFile keeps the name, the first log index and the number of rows of the open stream. A job restarted
+	// with the same file appends to the same stream after the rows that were already written. Without it a
+	// restarted job opens a new stream and writes the entries again.
+	StateFile string
+	// BatchSize is the number of rows in an append, defaults to 500.
+	BatchSize int
+	// MaxPending is the number of entries waiting behind a missing log index after which the stream is
+	// finalized and a new one is started after the gap, defaults to 10000. The missing log index is left
+	// for the missing entries job.
+	MaxPending int
+	// MaxWait is the time entries wait behind a missing log index before the stream is replaced the same way,
+	// defaults to 1m.
+	MaxWait time.Duration
+	// OnGap is called with the first and the last log index of a gap the stream was replaced after.
+	OnGap func(first, last int64)
+}
+
+// storageWriteState is the content of the StateFile.
+type storageWriteState struct {
+	Stream string `json:"stream"`
+	Start  int64  `json:"start"`
+	// Rows is the number of rows appended to the stream, it is written after every append.
+	Rows int64 `json:"rows"`
+}
+
+// errRowsExist is returned by append when the stream already has a row at the offset.
+var errRowsExist = errors.New("rows already exist at the offset")
+
+// committedStream is the part of a committed write stream used by the sink.
+type committedStream interface {
+	// append writes the rows at offset, it fails with errRowsExist when offset is before the end of the stream.
+	append(offset int64, rows [][]byte) error
+	// finalize closes the stream for appends.
+	finalize() error
+	name() string
+}
+
+type storageWriteSink struct {
+	opts StorageWriteOptions
+	// open opens the stream with the given name, or a new one when the name is empty.
+	open       func(name string) (committedStream, error)
+	client     *managedwriter.Client
+	descriptor protoreflect.MessageDescriptor
+	mu         sync.Mutex
+	stream     committedStream
+	start      int64
+	next       int64
+	pending    map[int64]Entry
+	// blocked is the time the entries started waiting behind a missing log index.
+	blocked time.Time
+}
+
+// NewStorageWriteSink returns a Sink that writes the entries to BigQuery through a committed Storage Write API
+// stream. Every entry is appended at the offset derived from its log index, so a retried append, or a restarted
+// job with the same StateFile, cannot write a row twice. Rows are only written exactly once when a StateFile is
+// set. Entries are appended in log index order, out of order entries wait until the entries before them were
+// written. The stream writes to the project of the repository.
+func NewStorageWriteSink(bq *BigQuery, opts StorageWriteOptions) (Sink, error) {
+	if bq == nil {
+		return nil, fmt.Errorf("bigquery repository is required")
+	}
+	if opts.Dataset == "" {
+		return nil, fmt.Errorf("dataset is required")
+	}
+	if opts.Table == "" {
+		return nil, fmt.Errorf("table is required")
+	}
+	descriptor, descriptorProto, err := entryDescriptor()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	client, err := managedwriter.NewClient(ctx, bq.Project())
+	if err != nil {
+		return nil, fmt.Errorf("managedwriter.NewClient: %w", err)
+	}
+	table := managedwriter.TableParentFromParts(bq.Project(), opts.Dataset, opts.Table)
+	open := func(name string) (committedStream, error) {
+		options := []managedwriter.WriterOption{managedwriter.WithSchemaDescriptor(descriptorProto)}
+		if name != "" {
+			options = append(options, managedwriter.WithStreamName(name))
+		} else {
+			options = append(options, managedwriter.WithDestinationTable(table),
+				managedwriter.WithType(managedwriter.CommittedStream))
+		}
+		ms, err := client.NewManagedStream(ctx, options...)
+		if err != nil {
+			return nil, fmt.Errorf("NewManagedStream: %w", err)
+		}
+		return &managedStream{ms: ms}, nil
+	}
+	s, err := newStorageWriteSink(opts, open, descriptor)
+	if err != nil {
+		//nolint
+		client.Close()
+		return nil, err
+	}
+	s.client = client
+	return s, nil
+}
+
+func newStorageWriteSink(opts StorageWriteOptions, open func(string) (committedStream, error),
+	descriptor protoreflect.MessageDescriptor) (*storageWriteSink, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.MaxPending <= 0 {
+		opts.MaxPending = 10000
+	}
+	if opts.MaxWait <= 0 {
+		opts.MaxWait = time.Minute
+	}
+	if opts.OnGap == nil {
+		opts.OnGap = func(first, last int64) {}
+	}
+	s := &storageWriteSink{opts: opts, open: open, descriptor: descriptor, pending: map[int64]Entry{}}
+	state, err := s.readState()
+	if err != nil {
+		return nil, err
+	}
+	if state.Stream != "" && state.Start <= opts.Start && opts.Start <= state.Start+state.Rows {
+		// the entries before the end of the stream were written by the earlier job.
+		s.stream, err = open(state.Stream)
+		s.start, s.next = state.Start, state.Start+state.Rows
+	} else {
+		s.stream, err = open("")
+		s.start, s.next = opts.Start, opts.Start
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.writeState(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *storageWriteSink) readState() (storageWriteState, error) {
+	var state storageWriteState
+	if s.opts.StateFile == "" {
+		return state, nil
+	}
+	data, err := os.ReadFile(s.opts.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("invalid state file %s %w", s.opts.StateFile, err)
+	}
+	return state, nil
+}
+
+func (s *storageWriteSink) writeState() error {
+	if s.opts.StateFile == "" {
+		return nil
+	}
+	data, err := Marshal(storageWriteState{Stream: s.stream.name(), Start: s.start, Rows: s.next - s.start})
+	if err != nil {
+		return err
+	}
+	tmp := s.opts.StateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+	return os.Rename(tmp, s.opts.StateFile)
+}
+
+func (s *storageWriteSink) Write(entry Entry) error {
+	return s.WriteBatch([]Entry{entry})
+}
+
+func (s *storageWriteSink) WriteBatch(entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range entries {
+		if int64(entry.LogIndex) < s.next {
+			// already appended, or before the start of the stream.
+			continue
+		}
+		s.pending[int64(entry.LogIndex)] = entry
+	}
+	if err := s.drain(false); err != nil {
+		return err
+	}
+	if !s.gap() {
+		s.blocked = time.Time{}
+		return nil
+	}
+	if s.blocked.IsZero() {
+		s.blocked = time.Now()
+	}
+	if len(s.pending) >= s.opts.MaxPending || time.Since(s.blocked) >= s.opts.MaxWait {
+		s.blocked = time.Time{}
+		return s.skipGap()
+	}
+	return nil
+}
+
+// gap reports whether entries are waiting behind a missing log index.
+func (s *storageWriteSink) gap() bool {
+	n := 0
+	for {
+		if _, ok := s.pending[s.next+int64(n)]; !ok {
+			return len(s.pending) > n
+		}
+		n++
+	}
+}
+
+// drain appends the entries that follow the last appended one without a gap, a partial batch is only
+// appended when all is set.
+func (s *storageWriteSink) drain(all bool) error {
+	for {
+		var rows [][]byte
+		for len(rows) < s.opts.BatchSize {
+			entry, ok := s.pending[s.next+int64(len(rows))]
+			if !ok {
+				break
+			}
+			row, err := encodeEntryRow(s.descriptor, entry)
+			if err != nil {
+				return fmt.Errorf("failed to encode entry %d %w", entry.LogIndex, err)
+			}
+			rows = append(rows, row)
+		}
+		if len(rows) == 0 || (len(rows) < s.opts.BatchSize && !all) {
+			return nil
+		}
+		if err := s.appendRows(s.next-s.start, rows); err != nil {
+			return fmt.Errorf("failed to append entries %d-%d %w", s.next, s.next+int64(len(rows))-1, err)
+		}
+		for i := range rows {
+			delete(s.pending, s.next+int64(i))
+		}
+		s.next += int64(len(rows))
+		if err := s.writeState(); err != nil {
+			return err
+		}
+	}
+}
+
+// appendRows appends the rows at offset. The state file is written after the append, so a job that stopped
+// in between appends some rows again. The stream rejects such a batch as a whole, it is then appended row by
+// row and a single row that exists is the one the earlier job wrote, its offset is derived from the log index.
+func (s *storageWriteSink) appendRows(offset int64, rows [][]byte) error {
+	err := s.stream.append(offset, rows)
+	if !errors.Is(err, errRowsExist) {
+		return err
+	}
+	if len(rows) == 1 {
+		return nil
+	}
+	for i, row := range rows {
+		if err := s.stream.append(offset+int64(i), [][]byte{row}); err != nil && !errors.Is(err, errRowsExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipGap finalizes the stream and starts a new one at the first pending entry after the missing log indexes,
+// the gap is reported to OnGap.
+func (s *storageWriteSink) skipGap() error {
+	if err := s.drain(true); err != nil {
+		return err
+	}
+	if len(s.pending) == 0 {
+		return nil
+	}
+	indexes := make([]int64, 0, len(s.pending))
+	for i := range s.pending {
+		indexes = append(indexes, i)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	if err := s.stream.finalize(); err != nil {
+		return fmt.Errorf("failed to finalize stream %s %w", s.stream.name(), err)
+	}
+	stream, err := s.open("")
+	if err != nil {
+		return err
+	}
+	missing := s.next
+	s.stream, s.start, s.next = stream, indexes[0], indexes[0]
+	if err := s.writeState(); err != nil {
+		return err
+	}
+	s.opts.OnGap(missing, indexes[0]-1)
+	return s.drain(true)
+}
+
+// Flush appends the entries that follow the last appended one, entries behind a missing log index keep waiting.
+func (s *storageWriteSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.drain(true)
+}
+
+// Close appends the pending entries, skipping the missing log indexes, finalizes the stream and removes the
+// state file.
+func (s *storageWriteSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	err := s.drain(true)
+	for err == nil && len(s.pending) > 0 {
+		err = s.skipGap()
+	}
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if err := s.stream.finalize(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to finalize stream %s %w", s.stream.name(), err))
+	} else if s.opts.StateFile != "" && len(errs) == 0 {
+		if err := os.Remove(s.opts.StateFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	if s.client != nil {
+		if err := s.client.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// managedStream is a committedStream backed by the Storage Write API.
+type managedStream struct {
+	ms *managedwriter.ManagedStream
+}
+
+func (m *managedStream) append(offset int64, rows [][]byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	result, err := m.ms.AppendRows(ctx, rows, managedwriter.WithOffset(offset))
+	if err != nil {
+		return err
+	}
+	_, err = result.GetResult(ctx)
+	if status.Code(err) == codes.AlreadyExists {
+		return errRowsExist
+	}
+	return err
+}
+
+func (m *managedStream) finalize() error {
+	if _, err := m.ms.Finalize(context.Background()); err != nil {
+		return err
+	}
+	return m.ms.Close()
+}
+
+func (m *managedStream) name() string {
+	return m.ms.StreamName()
+}
+
+// entryDescriptor returns the proto descriptor of the rows, derived from the table schema, and its
+// self-contained DescriptorProto the stream is opened with.
+func entryDescriptor() (protoreflect.MessageDescriptor, *descriptorpb.DescriptorProto, error) {
+	root := &descriptorpb.DescriptorProto{Name: proto.String("Entry")}
+	if err := addSchemaFields(root, root, "", entrySchema); err != nil {
+		return nil, nil, err
+	}
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("entry.proto"),
+		Syntax:      proto.String("proto2"),
+		MessageType: []*descriptorpb.DescriptorProto{root},
+	}, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("protodesc.NewFile: %w", err)
+	}
+	return file.Messages().Get(0), root, nil
+}
+
+// addSchemaFields adds the fields of the schema to msg. The messages of the records are nested in root and
+// named after their path, records with the same fields in different places get their own message.
+func addSchemaFields(root, msg *descriptorpb.DescriptorProto, path string, schema bigquery.Schema) error {
+	for i, field := range schema {
+		fd := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(field.Name),
+			Number: proto.Int32(int32(i + 1)),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if field.Repeated {
+			fd.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		}
+		switch field.Type {
+		case bigquery.StringFieldType:
+			fd.Type = descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()
+		case bigquery.BytesFieldType:
+			fd.Type = descriptorpb.FieldDescriptorProto_TYPE_BYTES.Enum()
+		case bigquery.IntegerFieldType, bigquery.TimestampFieldType:
+			fd.Type = descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum()
+		case bigquery.FloatFieldType:
+			fd.Type = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE.Enum()
+		case bigquery.BooleanFieldType:
+			fd.Type = descriptorpb.FieldDescriptorProto_TYPE_BOOL.Enum()
+		case bigquery.RecordFieldType:
+			// the Record suffix keeps the message names apart from the field names of Entry.
+			name := path + field.Name + "Record"
+			nested := &descriptorpb.DescriptorProto{Name: proto.String(name)}
+			root.NestedType = append(root.NestedType, nested)
+			if err := addSchemaFields(root, nested, path+field.Name+"_", field.Schema); err != nil {
+				return err
+			}
+			fd.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+			fd.TypeName = proto.String(".Entry." + name)
+		default:
+			return fmt.Errorf("column %s has unsupported type %s", field.Name, field.Type)
+		}
+		msg.Field = append(msg.Field, fd)
+	}
+	return nil
+}
+
+// encodeEntryRow encodes the entry as a serialized proto row, using the same values the inserter saves.
+func encodeEntryRow(descriptor protoreflect.MessageDescriptor, e Entry) ([]byte, error) {
+	row, _, err := entrySaver{entry: e}.Save()
+	if err != nil {
+		return nil, err
+	}
+	msg := dynamicpb.NewMessage(descriptor)
+	if err := setRowFields(msg, row); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+// setRowFields sets the fields of msg from the saved row, nested records become nested messages.
+func setRowFields(msg *dynamicpb.Message, row map[string]bigquery.Value) error {
+	fields := msg.Descriptor().Fields()
+	for name, value := range row {
+		if value == nil {
+			continue
+		}
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			return fmt.Errorf("unknown column %s", name)
+		}
+		if fd.IsList() {
+			// repeated records are []bigquery.Value, repeated scalars are typed slices such as []string.
+			values := reflect.ValueOf(value)
+			if values.Kind() != reflect.Slice {
+				return fmt.Errorf("column %s: unexpected %T", name, value)
+			}
+			list := msg.Mutable(fd).List()
+			for i := 0; i < values.Len(); i++ {
+				pv, err := protoValue(fd, values.Index(i).Interface())
+				if err != nil {
+					return fmt.Errorf("column %s: %w", name, err)
+				}
+				list.Append(pv)
+			}
+			continue
+		}
+		pv, err := protoValue(fd, value)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", name, err)
+		}
+		msg.Set(fd, pv)
+	}
+	return nil
+}
+
+func protoValue(fd protoreflect.FieldDescriptor, value bigquery.Value) (protoreflect.Value, error) {
+	switch v := value.(type) {
+	case map[string]bigquery.Value:
+		nested := dynamicpb.NewMessage(fd.Message())
+		if err := setRowFields(nested, v); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfMessage(nested), nil
+	case string:
+		return protoreflect.ValueOfString(v), nil
+	case bool:
+		return protoreflect.ValueOfBool(v), nil
+	case int:
+		return protoreflect.ValueOfInt64(int64(v)), nil
+	case int64:
+		return protoreflect.ValueOfInt64(v), nil
+	case float64:
+		return protoreflect.ValueOfFloat64(v), nil
+	case time.Time:
+		// TIMESTAMP columns are microseconds since the epoch.
+		return protoreflect.ValueOfInt64(v.UnixMicro()), nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported value %T", value)
+}
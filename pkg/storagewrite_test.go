@@ -0,0 +1,279 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// fakeStream is a committedStream that keeps the log index of every row at its offset. Like the Storage
+// Write API it rejects appends before the end of the stream as a whole.
+type fakeStream struct {
+	streamName string
+	rows       map[int64]int64
+	finalized  bool
+	descriptor protoreflect.MessageDescriptor
+}
+
+func (f *fakeStream) append(offset int64, rows [][]byte) error {
+	if offset < int64(len(f.rows)) {
+		return errRowsExist
+	}
+	if offset > int64(len(f.rows)) {
+		return fmt.Errorf("offset %d is after the end of the stream %d", offset, len(f.rows))
+	}
+	for i, row := range rows {
+		msg := dynamicpb.NewMessage(f.descriptor)
+		if err := proto.Unmarshal(row, msg); err != nil {
+			return err
+		}
+		f.rows[offset+int64(i)] = msg.Get(f.descriptor.Fields().ByName("LogIndex")).Int()
+	}
+	return nil
+}
+
+func (f *fakeStream) finalize() error {
+	f.finalized = true
+	return nil
+}
+
+func (f *fakeStream) name() string {
+	return f.streamName
+}
+
+func TestEncodeEntryRow(t *testing.T) {
+	descriptor, _, err := entryDescriptor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	row, err := encodeEntryRow(descriptor, Entry{LogIndex: 7, Kind: Kind{Kind: "rekord"}, Date: date, Rekord: &Rekord{
+		Signature: Signature{PGPKeys: []PGPKey{{UIDs: []PGPUID{{Email: "jane@example.com"}}}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := dynamicpb.NewMessage(descriptor)
+	if err := proto.Unmarshal(row, msg); err != nil {
+		t.Fatal(err)
+	}
+	fields := descriptor.Fields()
+	if got := msg.Get(fields.ByName("LogIndex")).Int(); got != 7 {
+		t.Errorf("LogIndex = %d", got)
+	}
+	if got := msg.Get(fields.ByName("Date")).Int(); got != date.UnixMicro() {
+		t.Errorf("Date = %d, want %d", got, date.UnixMicro())
+	}
+	kind := msg.Get(fields.ByName("Kind")).Message()
+	if got := kind.Get(kind.Descriptor().Fields().ByName("Kind")).String(); got != "rekord" {
+		t.Errorf("Kind.Kind = %q", got)
+	}
+}
+
+func TestEncodeEntryRowIdentities(t *testing.T) {
+	descriptor, _, err := entryDescriptor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshKey := append(bytes.TrimSpace(ssh.MarshalAuthorizedKey(sshPub)), []byte(" user@example.com")...)
+	tests := []struct {
+		format string
+		key    []byte
+		want   string
+	}{
+		{format: "pgp", key: []byte(testPGPKey), want: "alice@example.com"},
+		{format: "ssh", key: sshKey, want: "user@example.com"},
+		{format: "pkcs7", key: testPKCS7(t), want: "signer@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var sig Signature
+			if err := signatureParsers[tt.format](tt.key, &sig); err != nil {
+				t.Fatal(err)
+			}
+			// Identities is a repeated STRING column, the saver returns it as a []string
+			row, err := encodeEntryRow(descriptor, Entry{LogIndex: 1, Rekord: &Rekord{Signature: sig}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := dynamicpb.NewMessage(descriptor)
+			if err := proto.Unmarshal(row, msg); err != nil {
+				t.Fatal(err)
+			}
+			rekord := msg.Get(descriptor.Fields().ByName("Rekord")).Message()
+			signature := rekord.Get(rekord.Descriptor().Fields().ByName("Signature")).Message()
+			keys := signature.Get(signature.Descriptor().Fields().ByName("Keys")).List()
+			if keys.Len() != 1 {
+				t.Fatalf("Keys has %d rows, want 1", keys.Len())
+			}
+			key := keys.Get(0).Message()
+			identities := key.Get(key.Descriptor().Fields().ByName("Identities")).List()
+			if identities.Len() == 0 || identities.Get(0).String() != tt.want {
+				t.Errorf("Identities = %v, want %s first", identities, tt.want)
+			}
+		})
+	}
+}
+
+func TestStorageWriteSink(t *testing.T) {
+	descriptor, _, err := entryDescriptor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	streams := map[string]*fakeStream{}
+	open := func(name string) (committedStream, error) {
+		if name == "" {
+			name = "stream-" + string(rune('a'+len(streams)))
+		}
+		if _, ok := streams[name]; !ok {
+			streams[name] = &fakeStream{streamName: name, rows: map[int64]int64{}, descriptor: descriptor}
+		}
+		return streams[name], nil
+	}
+	var gaps [][2]int64
+	onGap := func(first, last int64) { gaps = append(gaps, [2]int64{first, last}) }
+	state := filepath.Join(t.TempDir(), "state.json")
+	opts := StorageWriteOptions{Start: 100, StateFile: state, BatchSize: 2, MaxPending: 3, OnGap: onGap}
+	s, err := newStorageWriteSink(opts, open, descriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// out of order entries are appended at the offset of their log index
+	for _, i := range []int{101, 100, 103, 102, 104} {
+		if err := s.Write(Entry{LogIndex: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[int64]int64{0: 100, 1: 101, 2: 102, 3: 103, 4: 104}
+	if !reflect.DeepEqual(streams["stream-a"].rows, want) {
+		t.Errorf("rows = %v, want %v", streams["stream-a"].rows, want)
+	}
+
+	// a restarted job reopens the stream from the state file and skips what was written
+	opts.Start = 103
+	s, err = newStorageWriteSink(opts, open, descriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 1 || s.start != 100 || s.next != 105 {
+		t.Fatalf("restart opened %d streams starting at %d, next %d", len(streams), s.start, s.next)
+	}
+	// 105 is missing, the stream is replaced once too many entries are waiting behind it
+	for _, i := range []int{103, 104, 106, 107} {
+		if err := s.Write(Entry{LogIndex: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Write(Entry{LogIndex: 108}); err != nil {
+		t.Fatal(err)
+	}
+	if want := [][2]int64{{105, 105}}; !reflect.DeepEqual(gaps, want) {
+		t.Errorf("gaps = %v, want %v", gaps, want)
+	}
+	if !streams["stream-a"].finalized || len(streams["stream-a"].rows) != 5 {
+		t.Errorf("stream-a finalized %v with %d rows", streams["stream-a"].finalized, len(streams["stream-a"].rows))
+	}
+	want = map[int64]int64{0: 106, 1: 107, 2: 108}
+	if !reflect.DeepEqual(streams["stream-b"].rows, want) {
+		t.Errorf("stream-b rows = %v, want %v", streams["stream-b"].rows, want)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.readState(); err != nil || !streams["stream-b"].finalized {
+		t.Errorf("Close() left state %v, finalized %v", err, streams["stream-b"].finalized)
+	}
+}
+
+func TestStorageWriteSinkResume(t *testing.T) {
+	descriptor, _, err := entryDescriptor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := &fakeStream{streamName: "stream-a", rows: map[int64]int64{0: 100, 1: 101, 2: 102}, descriptor: descriptor}
+	open := func(name string) (committedStream, error) {
+		if name != "stream-a" {
+			return nil, fmt.Errorf("opened stream %q, want stream-a", name)
+		}
+		return stream, nil
+	}
+	// the job stopped after it appended 101 and 102 but before it wrote the state file
+	state := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(state, []byte(`{"stream":"stream-a","start":100,"rows":1}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := newStorageWriteSink(StorageWriteOptions{Start: 100, StateFile: state, BatchSize: 4}, open, descriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 100; i <= 105; i++ {
+		if err := s.Write(Entry{LogIndex: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[int64]int64{0: 100, 1: 101, 2: 102, 3: 103, 4: 104, 5: 105}
+	if !reflect.DeepEqual(stream.rows, want) {
+		t.Errorf("rows = %v, want %v", stream.rows, want)
+	}
+}
+
+func TestStorageWriteSinkMaxWait(t *testing.T) {
+	descriptor, _, err := entryDescriptor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streams []*fakeStream
+	open := func(name string) (committedStream, error) {
+		stream := &fakeStream{streamName: fmt.Sprintf("stream-%d", len(streams)), rows: map[int64]int64{}, descriptor: descriptor}
+		streams = append(streams, stream)
+		return stream, nil
+	}
+	var gaps [][2]int64
+	s, err := newStorageWriteSink(StorageWriteOptions{BatchSize: 1, MaxWait: 10 * time.Millisecond,
+		OnGap: func(first, last int64) { gaps = append(gaps, [2]int64{first, last}) }}, open, descriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1 was never fetched, 2 and 3 are written once they waited for MaxWait
+	if err := s.WriteBatch([]Entry{{LogIndex: 0}, {LogIndex: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := s.Write(Entry{LogIndex: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if want := [][2]int64{{1, 1}}; !reflect.DeepEqual(gaps, want) {
+		t.Errorf("gaps = %v, want %v", gaps, want)
+	}
+	if len(streams) != 2 || !reflect.DeepEqual(streams[1].rows, map[int64]int64{0: 2, 1: 3}) {
+		t.Errorf("streams = %d, rows of the second = %v", len(streams), streams[len(streams)-1].rows)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,229 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/segmentio/kafka-go"
+)
+
+// StreamOptions configures the Kafka and NATS JetStream sinks.
+type StreamOptions struct {
+	// Brokers are the Kafka bootstrap brokers or the NATS server URLs.
+	Brokers []string
+	// Topic is the Kafka topic or the NATS subject the entries are published to.
+	Topic string
+	// Retries is the number of times a publish that was not acknowledged is retried, defaults to 5.
+	Retries int
+	// Backoff is the delay before the first retry, it doubles after every attempt, defaults to 500ms.
+	Backoff time.Duration
+}
+
+// streamMessage is an entry as it is published, keyed by log index with the kind and issuer as headers
+// so consumers can filter without decoding the value.
+type streamMessage struct {
+	Key     string
+	Value   []byte
+	Headers map[string]string
+}
+
+func newStreamMessage(e Entry) (streamMessage, error) {
+	value, err := Marshal(e)
+	if err != nil {
+		return streamMessage{}, fmt.Errorf("json.Marshal: %w", err)
+	}
+	sig, _ := signatureOf(e)
+	return streamMessage{
+		Key:   strconv.Itoa(e.LogIndex),
+		Value: value,
+		Headers: map[string]string{
+			"kind":   e.Kind.Kind,
+			"issuer": sig.issuer(),
+		},
+	}, nil
+}
+
+func (o StreamOptions) validate(name string) (StreamOptions, error) {
+	if len(o.Brokers) == 0 {
+		return o, fmt.Errorf("%s brokers are required", name)
+	}
+	if o.Topic == "" {
+		return o, fmt.Errorf("%s topic is required", name)
+	}
+	if o.Retries <= 0 {
+		o.Retries = 5
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = 500 * time.Millisecond
+	}
+	return o, nil
+}
+
+// retry calls f until it succeeds or the retries are exhausted, doubling the backoff after every attempt.
+func retry(retries int, backoff time.Duration, f func() error) error {
+	err := f()
+	for i := 0; err != nil && i < retries; i++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		err = f()
+	}
+	return err
+}
+
+// kafkaBatchTimeout is how long the writer waits for more messages before it sends a batch.
+const kafkaBatchTimeout = 10 * time.Millisecond
+
+type kafkaSink struct {
+	writer *kafka.Writer
+	opts   StreamOptions
+}
+
+// NewKafkaSink returns a Sink that publishes the entries to a Kafka topic. Messages are hashed to partitions
+// by log index and a write only succeeds once all in-sync replicas acknowledged it.
+func NewKafkaSink(opts StreamOptions) (Sink, error) {
+	opts, err := opts.validate("kafka")
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaSink{
+		opts: opts,
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(opts.Brokers...),
+			Topic:        opts.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			// the sink retries the failed messages itself, with backoff.
+			MaxAttempts: 1,
+			// every update worker writes synchronously, the default of a second would cap the throughput at
+			// one batch per worker and second. Concurrent writes within the timeout still share a batch.
+			BatchTimeout: kafkaBatchTimeout,
+		},
+	}, nil
+}
+
+func (s *kafkaSink) Write(entry Entry) error {
+	return s.WriteBatch([]Entry{entry})
+}
+
+// WriteBatch publishes the entries, retrying only the messages that were not acknowledged.
+func (s *kafkaSink) WriteBatch(entries []Entry) error {
+	msgs := make([]kafka.Message, 0, len(entries))
+	for _, entry := range entries {
+		m, err := newStreamMessage(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode entry %d %w", entry.LogIndex, err)
+		}
+		msg := kafka.Message{Key: []byte(m.Key), Value: m.Value}
+		for k, v := range m.Headers {
+			msg.Headers = append(msg.Headers, kafka.Header{Key: k, Value: []byte(v)})
+		}
+		msgs = append(msgs, msg)
+	}
+	err := retry(s.opts.Retries, s.opts.Backoff, func() error {
+		err := s.writer.WriteMessages(context.Background(), msgs...)
+		var werr kafka.WriteErrors
+		if errors.As(err, &werr) && len(werr) == len(msgs) {
+			var failed []kafka.Message
+			for i, e := range werr {
+				if e != nil {
+					failed = append(failed, msgs[i])
+				}
+			}
+			msgs = failed
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish %d messages to %s %w", len(msgs), s.opts.Topic, err)
+	}
+	return nil
+}
+
+// Flush is a no-op, WriteBatch returns once the messages are acknowledged.
+func (s *kafkaSink) Flush() error {
+	return nil
+}
+
+func (s *kafkaSink) Close() error {
+	return s.writer.Close()
+}
+
+type natsSink struct {
+	conn *nats.Conn
+	js   jetstream.JetStream
+	opts StreamOptions
+}
+
+// NewNATSSink returns a Sink that publishes the entries to a NATS JetStream subject. The subject must be
+// captured by a stream, every publish waits for the stream to acknowledge it. The log id and index are used
+// as the message id, so the stream drops the duplicates of a retried publish within its duplicate window.
+func NewNATSSink(opts StreamOptions) (Sink, error) {
+	opts, err := opts.validate("nats")
+	if err != nil {
+		return nil, err
+	}
+	conn, err := nats.Connect(strings.Join(opts.Brokers, ","))
+	if err != nil {
+		return nil, fmt.Errorf("nats.Connect: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("jetstream.New: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	if _, err := js.StreamNameBySubject(ctx, opts.Topic); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("no JetStream stream captures subject %q %w", opts.Topic, err)
+	}
+	return &natsSink{conn: conn, js: js, opts: opts}, nil
+}
+
+func (s *natsSink) Write(entry Entry) error {
+	m, err := newStreamMessage(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode entry %d %w", entry.LogIndex, err)
+	}
+	msg := nats.NewMsg(s.opts.Topic)
+	msg.Data = m.Value
+	msg.Header.Set("log-index", m.Key)
+	for k, v := range m.Headers {
+		msg.Header.Set(k, v)
+	}
+	id := fmt.Sprintf("%s-%d", entry.LogID, entry.LogIndex)
+	err = retry(s.opts.Retries, s.opts.Backoff, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+		_, err := s.js.PublishMsg(ctx, msg, jetstream.WithMsgID(id))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish entry %d to %s %w", entry.LogIndex, s.opts.Topic, err)
+	}
+	return nil
+}
+
+func (s *natsSink) WriteBatch(entries []Entry) error {
+	for _, entry := range entries {
+		if err := s.Write(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush is a no-op, Write returns once the stream acknowledged the message.
+func (s *natsSink) Flush() error {
+	return nil
+}
+
+func (s *natsSink) Close() error {
+	return s.conn.Drain()
+}
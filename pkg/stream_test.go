@@ -0,0 +1,169 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestNewStreamMessage(t *testing.T) {
+	m, err := newStreamMessage(Entry{LogIndex: 42, Kind: Kind{Kind: "hashedrekord"}, HashedRekord: &Hashedrekord{
+		Signature: RekordSignature{X509: &X509{IssuerOrganization: "sigstore.dev"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Key != "42" || m.Headers["kind"] != "hashedrekord" || m.Headers["issuer"] != "sigstore.dev" {
+		t.Errorf("newStreamMessage() = %q, %v", m.Key, m.Headers)
+	}
+	if !strings.Contains(string(m.Value), `"logIndex":42`) {
+		t.Errorf("newStreamMessage() value = %s", m.Value)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		retries  int
+		calls    int
+		wantErr  bool
+	}{
+		{name: "success", failures: 0, retries: 3, calls: 1},
+		{name: "recovers", failures: 2, retries: 3, calls: 3},
+		{name: "exhausted", failures: 5, retries: 3, calls: 4, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retry(tt.retries, time.Millisecond, func() error {
+				calls++
+				if calls <= tt.failures {
+					return errors.New("not acknowledged")
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr || calls != tt.calls {
+				t.Errorf("retry() = %v after %d calls, want error %v after %d", err, calls, tt.wantErr, tt.calls)
+			}
+		})
+	}
+}
+
+// TestKafkaSink needs a Kafka broker that creates topics on first use, e.g.
+//
+//	docker run -d -p 9092:9092 apache/kafka:3.7.0
+//	PHREN_TEST_KAFKA_BROKERS=localhost:9092 go test ./pkg -run Kafka
+func TestKafkaSink(t *testing.T) {
+	brokers := os.Getenv("PHREN_TEST_KAFKA_BROKERS")
+	if brokers == "" {
+		t.Skip("PHREN_TEST_KAFKA_BROKERS is not set")
+	}
+	topic := fmt.Sprintf("phren-test-%d", time.Now().UnixNano())
+	conn, err := kafka.Dial("tcp", strings.Split(brokers, ",")[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.CreateTopics(kafka.TopicConfig{Topic: topic, NumPartitions: 1, ReplicationFactor: 1}); err != nil {
+		t.Fatal(err)
+	}
+	sink, err := NewKafkaSink(StreamOptions{Brokers: strings.Split(brokers, ","), Topic: topic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.WriteBatch([]Entry{{LogIndex: 1, Kind: Kind{Kind: "rekord"}}, {LogIndex: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	// single writes are not held back by the batch timeout of the writer
+	started := time.Now()
+	for i := 3; i < 13; i++ {
+		if err := sink.Write(Entry{LogIndex: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("10 writes took %v", elapsed)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: strings.Split(brokers, ","), Topic: topic})
+	defer reader.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	msg, err := reader.ReadMessage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Key) != "1" || len(msg.Headers) != 2 {
+		t.Errorf("message key %q, headers %v", msg.Key, msg.Headers)
+	}
+}
+
+// TestNATSSink needs a NATS server with JetStream enabled, e.g.
+//
+//	docker run -d -p 4222:4222 nats:2.10 -js
+//	PHREN_TEST_NATS_URL=nats://localhost:4222 go test ./pkg -run NATS
+func TestNATSSink(t *testing.T) {
+	url := os.Getenv("PHREN_TEST_NATS_URL")
+	if url == "" {
+		t.Skip("PHREN_TEST_NATS_URL is not set")
+	}
+	name := fmt.Sprintf("phren-test-%d", time.Now().UnixNano())
+	conn, err := nats.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	js, err := jetstream.New(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	stream, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: name, Subjects: []string{name + ".entries"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//nolint
+	defer js.DeleteStream(ctx, name)
+
+	if _, err := NewNATSSink(StreamOptions{Brokers: []string{url}, Topic: name + ".unknown"}); err == nil {
+		t.Error("NewNATSSink() of a subject without stream succeeded")
+	}
+	sink, err := NewNATSSink(StreamOptions{Brokers: []string{url}, Topic: name + ".entries"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := Entry{LogID: "c0d23d6a", LogIndex: 7, Kind: Kind{Kind: "intoto"}}
+	// publishing the same entry twice is deduplicated by the message id
+	if err := sink.WriteBatch([]Entry{entry, entry}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := stream.GetLastMsgForSubject(ctx, name+".entries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Header.Get("log-index") != "7" || msg.Header.Get("kind") != "intoto" {
+		t.Errorf("message headers %v", msg.Header)
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 1 {
+		t.Errorf("stream has %d messages, want 1", info.State.Msgs)
+	}
+}
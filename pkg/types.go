@@ -75,6 +75,8 @@ type tlog struct {
 type TLog interface {
 	Size() (int64, error)
 	Entry(index int64) (Entry, error)
+	// RawEntry returns the unmodified rekor API response for the given log index.
+	RawEntry(index int64) ([]byte, error)
 }
 type RekordData struct {
 	Hash RekorDataHash `json:"hash"`
@@ -84,10 +86,54 @@ type RekorDataHash struct {
 	Value     string `json:"value"`
 }
 type Signature struct {
-	Format    string `json:"format,omitempty"`
-	PublicKey string `json:"publicKey,omitempty"`
-	PGP       string `json:"pgp,omitempty"`
-	X509      *X509  `json:"x509,omitempty"`
+	Format        string         `json:"format,omitempty"`
+	PublicKey     string         `json:"publicKey,omitempty"`
+	PublicKeyInfo *PublicKeyInfo `json:"public_key_info,omitempty"`
+	// PGP is the concatenated user IDs of the PGP keys, the column existing tables have.
+	PGP string `json:"pgp,omitempty"`
+	// PGPKeys is the structured metadata of the PGP keys.
+	PGPKeys []PGPKey       `json:"pgp_keys,omitempty"`
+	X509    *X509          `json:"x509,omitempty"`
+	Keys    []SignatureKey `json:"keys,omitempty"`
+}
+
+// SignatureKey is the format independent view of a key found in a rekord signature.
+// Every signature format parser reports its keys in this shape.
+type SignatureKey struct {
+	Format      string   `json:"format,omitempty"`
+	Algorithm   string   `json:"algorithm,omitempty"`
+	Fingerprint string   `json:"fingerprint,omitempty"`
+	KeyID       string   `json:"key_id,omitempty"`
+	Identities  []string `json:"identities,omitempty"`
+}
+
+// PGPKey describes a single OpenPGP key (entity) found in a rekord public key.
+type PGPKey struct {
+	Fingerprint    string      `json:"fingerprint,omitempty"`
+	KeyID          string      `json:"key_id,omitempty"`
+	Algorithm      string      `json:"algorithm,omitempty"`
+	Bits           int         `json:"bits,omitempty"`
+	CreationTime   time.Time   `json:"creation_time,omitempty"`
+	ExpirationTime time.Time   `json:"expiration_time,omitempty"`
+	Subkeys        []PGPSubkey `json:"subkeys,omitempty"`
+	UIDs           []PGPUID    `json:"uids,omitempty"`
+}
+
+// PGPSubkey describes a subkey bound to a PGPKey.
+type PGPSubkey struct {
+	Fingerprint    string    `json:"fingerprint,omitempty"`
+	KeyID          string    `json:"key_id,omitempty"`
+	Algorithm      string    `json:"algorithm,omitempty"`
+	Bits           int       `json:"bits,omitempty"`
+	CreationTime   time.Time `json:"creation_time,omitempty"`
+	ExpirationTime time.Time `json:"expiration_time,omitempty"`
+}
+
+// PGPUID is a user ID of a PGPKey split into its name, comment and email parts.
+type PGPUID struct {
+	Name    string `json:"name,omitempty"`
+	Comment string `json:"comment,omitempty"`
+	Email   string `json:"email,omitempty"`
 }
 type Rekord struct {
 	apiVersion string
@@ -115,8 +161,19 @@ type Hashedrekord struct {
 	Signature  RekordSignature `json:"signature"`
 }
 type RekordSignature struct {
-	PublicKey string `json:"publicKey,omitempty"`
-	X509      *X509  `json:"x509,omitempty"`
+	PublicKey     string         `json:"publicKey,omitempty"`
+	PublicKeyInfo *PublicKeyInfo `json:"public_key_info,omitempty"`
+	X509          *X509          `json:"x509,omitempty"`
+}
+
+// PublicKeyInfo describes a PKIX public key, either bare or taken from a certificate.
+// FingerprintSHA256 is the hex encoded SHA-256 of the DER SubjectPublicKeyInfo, which is
+// stable across entries and can be used to find key reuse.
+type PublicKeyInfo struct {
+	Algorithm         string `json:"algorithm,omitempty"`
+	Curve             string `json:"curve,omitempty"`
+	Bits              int    `json:"bits,omitempty"`
+	FingerprintSHA256 string `json:"fingerprint_sha256,omitempty"`
 }
 type InToTo struct {
 	apiVersion string
@@ -132,6 +189,11 @@ type Entry struct {
 	HashedRekord   *Hashedrekord `json:"hashedrekord,omitempty"`
 	Intoto         *InToTo       `json:"intoto,omitempty"`
 	Date           time.Time     `json:"date"`
+	// DecoderVersion is the version of the decoder of the kind that produced this entry, see DecoderVersions.
+	DecoderVersion int `json:"decoder_version"`
+	// ParseError is set when the body could not be decoded, Body then holds the raw base64 body.
+	ParseError string `json:"parse_error,omitempty"`
+	Body       string `json:"body,omitempty"`
 }
 type Kind struct {
 	APIVersion string `json:"apiVersion"`
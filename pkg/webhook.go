@@ -0,0 +1,203 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WebhookSignatureHeader carries the hex encoded HMAC-SHA256 of the request body, prefixed with sha256=.
+const WebhookSignatureHeader = "X-Phren-Signature-256"
+
+// WebhookOptions configures the webhook sink.
+type WebhookOptions struct {
+	// URLs receive every batch.
+	URLs []string
+	// Secret is the HMAC key the request bodies are signed with, requests are not signed when it is empty.
+	Secret string
+	// MaxEntries is the number of entries after which a batch is sent, defaults to 100.
+	MaxEntries int
+	// MaxLatency is the time after which a batch is sent even if it is not full, defaults to 5s.
+	MaxLatency time.Duration
+	// Retries is the number of times a failed request is retried, defaults to 5.
+	Retries int
+	// Backoff is the delay before the first retry, it doubles after every attempt, defaults to 500ms.
+	Backoff time.Duration
+	// Client sends the requests, defaults to a client with a 30s timeout.
+	Client *http.Client
+}
+
+type webhookSink struct {
+	opts WebhookOptions
+	// sendMu serializes the requests so Flush can wait for a batch the timer is sending, it is taken before mu.
+	sendMu sync.Mutex
+	mu     sync.Mutex
+	batch  []Entry
+	timer  *time.Timer
+	// err is the error of a batch sent by the timer, it is returned by the next call.
+	err error
+}
+
+// NewWebhookSink returns a Sink that POSTs the entries as a JSON array to the URLs, in batches that are sent
+// when MaxEntries is reached or MaxLatency has passed since the first entry of the batch was written.
+func NewWebhookSink(opts WebhookOptions) (Sink, error) {
+	if len(opts.URLs) == 0 {
+		return nil, fmt.Errorf("webhook url is required")
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 100
+	}
+	if opts.MaxLatency <= 0 {
+		opts.MaxLatency = 5 * time.Second
+	}
+	if opts.Retries <= 0 {
+		opts.Retries = 5
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 500 * time.Millisecond
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &webhookSink{opts: opts}, nil
+}
+
+func (s *webhookSink) Write(entry Entry) error {
+	return s.WriteBatch([]Entry{entry})
+}
+
+func (s *webhookSink) WriteBatch(entries []Entry) error {
+	s.mu.Lock()
+	if err := s.takeErr(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	var full [][]Entry
+	for _, entry := range entries {
+		if len(s.batch) == 0 {
+			s.timer = time.AfterFunc(s.opts.MaxLatency, s.timedFlush)
+		}
+		s.batch = append(s.batch, entry)
+		if len(s.batch) >= s.opts.MaxEntries {
+			full = append(full, s.take())
+		}
+	}
+	s.mu.Unlock()
+	if len(full) == 0 {
+		return nil
+	}
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	var errs []error
+	for _, batch := range full {
+		if err := s.send(batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// timedFlush sends the batch once MaxLatency has passed.
+func (s *webhookSink) timedFlush() {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	s.mu.Lock()
+	batch := s.take()
+	s.mu.Unlock()
+	if err := s.send(batch); err != nil {
+		s.mu.Lock()
+		if s.err == nil {
+			s.err = err
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *webhookSink) takeErr() error {
+	err := s.err
+	s.err = nil
+	return err
+}
+
+func (s *webhookSink) Flush() error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	s.mu.Lock()
+	batch := s.take()
+	err := s.takeErr()
+	s.mu.Unlock()
+	return errors.Join(err, s.send(batch))
+}
+
+// take stops the timer and returns the batch, it is called with mu held.
+func (s *webhookSink) take() []Entry {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	batch := s.batch
+	s.batch = nil
+	return batch
+}
+
+// send posts the batch to every URL, it is called with sendMu held so writers only wait for mu while
+// the requests and their retries are in flight. The batch is dropped after it was sent or the retries
+// are exhausted.
+func (s *webhookSink) send(batch []Entry) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	body, err := Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	var errs []error
+	for _, url := range s.opts.URLs {
+		if err := retry(s.opts.Retries, s.opts.Backoff, func() error { return s.post(url, body) }); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send %d entries to %s %w", len(batch), url, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *webhookSink) post(url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.opts.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook([]byte(s.opts.Secret), body))
+	}
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	//nolint
+	defer resp.Body.Close()
+	//nolint
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *webhookSink) Close() error {
+	return s.Flush()
+}
+
+// SignWebhook returns the value of the WebhookSignatureHeader for the body, receivers compare it
+// with hmac.Equal against the value they compute with the shared secret.
+func SignWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,161 @@
+package pkg
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver records the batches it receives and fails the first failures requests.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	failures int
+	requests int
+	batches  [][]Entry
+	bad      int
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+	if r.requests <= r.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if req.Header.Get(WebhookSignatureHeader) != SignWebhook([]byte("secret"), body) {
+		r.bad++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var batch []Entry
+	if err := json.Unmarshal(body, &batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.batches = append(r.batches, batch)
+}
+
+func (r *webhookReceiver) sizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sizes []int
+	for _, b := range r.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+func TestWebhookSink(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		entries  int
+		want     []int
+		retries  int
+		wantErr  bool
+	}{
+		{name: "batches by count", entries: 5, want: []int{2, 2, 1}},
+		{name: "retries", failures: 2, entries: 2, want: []int{2}},
+		{name: "retries exhausted", failures: 10, entries: 2, retries: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := &webhookReceiver{failures: tt.failures}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+			sink, err := NewWebhookSink(WebhookOptions{URLs: []string{server.URL}, Secret: "secret", MaxEntries: 2,
+				MaxLatency: time.Hour, Retries: tt.retries, Backoff: time.Millisecond})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.entries; i++ {
+				if err = sink.Write(Entry{LogIndex: i}); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				err = sink.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := receiver.sizes(); !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("batch sizes = %v, want %v", got, tt.want)
+			}
+			if receiver.bad != 0 {
+				t.Errorf("%d requests had a bad signature", receiver.bad)
+			}
+		})
+	}
+}
+
+func TestWebhookSinkLatency(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	sink, err := NewWebhookSink(WebhookOptions{URLs: []string{server.URL}, Secret: "secret", MaxEntries: 100,
+		MaxLatency: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if err := sink.Write(Entry{LogIndex: 1}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(receiver.sizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := receiver.sizes(); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("batch sizes = %v, want [1]", got)
+	}
+}
+
+func TestWebhookSinkSlowReceiver(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+		<-release
+	}))
+	defer server.Close()
+	sink, err := NewWebhookSink(WebhookOptions{URLs: []string{server.URL}, MaxEntries: 2, MaxLatency: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := make(chan error, 1)
+	go func() { sent <- sink.WriteBatch([]Entry{{LogIndex: 1}, {LogIndex: 2}}) }()
+	<-received
+	// the full batch is in flight, a write that does not fill the next batch must not wait for it
+	written := make(chan error, 1)
+	go func() { written <- sink.Write(Entry{LogIndex: 3}) }()
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write blocked while a batch was being sent")
+	}
+	close(release)
+	if err := <-sent; err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/naveensrinivasan/rekor-phren/pkg"
+)
+
+// reprocessBatchSize is the number of entries whose rows are replaced in BigQuery at once.
+const reprocessBatchSize = 500
+
+// entryReplacer replaces the rows of re-decoded entries, *pkg.BigQuery implements it.
+type entryReplacer interface {
+	ReplaceEntries(dataset, table string, entries []pkg.Entry) error
+}
+
+// reprocess re-decodes the archived raw rekor responses between start and end with the current parsers and
+// rewrites the BigQuery rows and the entry.json objects. If kinds is not empty only entries of those kinds are rewritten.
+// Entries without an archived raw response are fetched from rekor.
+func reprocess(archive pkg.Bucket, rows entryReplacer, rekor pkg.TLog, start, end int64, concurrency int,
+	kinds []string) error {
+	if end < start {
+		return fmt.Errorf("end-index %d is before start-index %d", end, start)
+	}
+	filter := make(map[string]bool, len(kinds))
+	for _, k := range kinds {
+		filter[k] = true
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(concurrency)
+	var ch = make(chan int64)
+	var entries = make(chan pkg.Entry)
+
+	// consumer
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			for i := range ch {
+				raw, err := archive.RawEntry(i)
+				if err != nil {
+					// entries archived before the raw response was kept are fetched from rekor again.
+					raw, err = rekor.RawEntry(i)
+					if err != nil {
+						handleErr(fmt.Errorf("failed to read raw entry %d %w", i, err))
+						continue
+					}
+					if err := archive.UpdateBucketRaw(i, raw); err != nil {
+						handleErr(fmt.Errorf("failed to update bucket with raw entry %d %w", i, err))
+					}
+				}
+				data, err := pkg.DecodeEntry(raw)
+				if err != nil {
+					handleErr(fmt.Errorf("failed to decode entry %d %w", i, err))
+					continue
+				}
+				if len(filter) > 0 && !filter[data.Kind.Kind] {
+					continue
+				}
+				if err := archive.UpdateBucket(data); err != nil {
+					handleErr(fmt.Errorf("failed to update bucket %d %w", i, err))
+				}
+				entries <- data
+			}
+			wg.Done()
+		}()
+	}
+
+	// producer
+	go func() {
+		for i := start; i <= end; i++ {
+			ch <- i
+		}
+		close(ch)
+	}()
+
+	go func() {
+		wg.Wait()
+		close(entries)
+	}()
+
+	batch := make([]pkg.Entry, 0, reprocessBatchSize)
+	for data := range entries {
+		batch = append(batch, data)
+		if len(batch) == reprocessBatchSize {
+			replaceRows(rows, batch)
+			batch = batch[:0]
+		}
+	}
+	replaceRows(rows, batch)
+	return nil
+}
+
+// replaceRows replaces the existing rows of the entries with the re-decoded ones, the old rows are kept when
+// it fails.
+func replaceRows(rows entryReplacer, batch []pkg.Entry) {
+	if len(batch) == 0 {
+		return
+	}
+	first, last := batch[0].LogIndex, batch[len(batch)-1].LogIndex
+	if err := rows.ReplaceEntries(dataset, tableName, batch); err != nil {
+		handleErr(fmt.Errorf("failed to replace entries %d-%d %w", first, last, err))
+		return
+	}
+	fmt.Println("Reprocessed", len(batch), "entries up to", last)
+}
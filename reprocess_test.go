@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/naveensrinivasan/rekor-phren/pkg"
+)
+
+// fakeReplacer records the replaced entries.
+type fakeReplacer struct {
+	mu      sync.Mutex
+	entries []pkg.Entry
+}
+
+func (f *fakeReplacer) ReplaceEntries(dataset, table string, entries []pkg.Entry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries = append(f.entries, entries...)
+	return nil
+}
+
+func (f *fakeReplacer) indexes() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	indexes := make([]int, 0, len(f.entries))
+	for _, entry := range f.entries {
+		indexes = append(indexes, entry.LogIndex)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// testRawEntry returns a rekor API response holding an entry of the given kind.
+func testRawEntry(t *testing.T, index int, kind string) []byte {
+	body, err := json.Marshal(map[string]interface{}{"apiVersion": "0.0.1", "kind": kind, "spec": map[string]interface{}{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(map[string]interface{}{
+		fmt.Sprintf("uuid%d", index): map[string]interface{}{"body": base64.StdEncoding.EncodeToString(body),
+			"logIndex": index, "logID": "id", "integratedTime": 1672628645},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestReprocess(t *testing.T) {
+	e = log.New(io.Discard, "", 0)
+	// index 3 was archived before the raw responses were kept and is fetched from rekor, 4 is nowhere.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("logIndex") != "3" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(testRawEntry(t, 3, "hashedrekord"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name  string
+		kinds []string
+		want  []int
+	}{
+		{name: "all kinds", want: []int{0, 1, 2, 3}},
+		{name: "intoto", kinds: []string{"intoto"}, want: []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			archive, err := pkg.NewDirectory(root, pkg.DirectoryOptions{FanOut: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, kind := range []string{"hashedrekord", "intoto", "rekord"} {
+				if err := archive.UpdateBucketRaw(int64(i), testRawEntry(t, i, kind)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rows := &fakeReplacer{}
+			if err := reprocess(archive, rows, pkg.NewTLog(server.URL), 0, 4, 2, tt.kinds); err != nil {
+				t.Fatal(err)
+			}
+			if got := rows.indexes(); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("replaced %v, want %v", got, tt.want)
+			}
+			for _, entry := range rows.entries {
+				if entry.DecoderVersion != pkg.DecoderVersions[entry.Kind.Kind] {
+					t.Errorf("entry %d decoded by version %d", entry.LogIndex, entry.DecoderVersion)
+				}
+			}
+			// the fetched raw response is archived and the rewritten entries are stored next to it.
+			if _, err := archive.RawEntry(3); err != nil {
+				t.Errorf("raw entry 3 was not archived: %v", err)
+			}
+			for _, index := range tt.want {
+				if _, err := os.Stat(filepath.Join(root, "0", "0", fmt.Sprint(index), "entry.json")); err != nil {
+					t.Errorf("entry.json of %d was not written: %v", index, err)
+				}
+			}
+		})
+	}
+}
+
+func TestReprocessInvalidRange(t *testing.T) {
+	if err := reprocess(nil, &fakeReplacer{}, nil, 5, 4, 1, nil); err == nil {
+		t.Error("reprocess() error = nil for an end before the start")
+	}
+}